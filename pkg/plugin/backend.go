@@ -2,10 +2,14 @@ package plugin
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"sync"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -19,71 +23,171 @@ func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend,
 
 // ccloudBackend defines an object that
 // extends the Vault backend and stores the
-// target API's client.
+// target API's clients, one per connection.
 type ccloudBackend struct {
 	*framework.Backend
-	lock   sync.RWMutex
-	client *ccloudAPIKeyClient
+	lock    sync.RWMutex
+	clients map[string]*ccloudAPIKeyClient
+
+	// rotationLock serializes rotations of the root credential and changes
+	// to the configuration
+	rotationLock sync.Mutex
+
+	// roleLocks serialize changes to a role, such as to the shared keys of
+	// a multi-use role, by role name
+	roleLocks []*locksutil.LockEntry
+
+	// staticRoleLock serializes changes to static roles and the rotations
+	// of their keys
+	staticRoleLock sync.Mutex
+
+	// libraryLock serializes changes to library sets and check-outs
+	libraryLock sync.Mutex
+
+	// poolLock serializes changes to the key pools of roles. Once the
+	// backend is initialized, the pool worker refills them from poolRefills
+	// until stopPoolWorker is called.
+	poolLock       sync.Mutex
+	poolRefills    chan string
+	poolWorker     sync.Once
+	poolWorkerCtx  context.Context
+	stopPoolWorker context.CancelFunc
 }
 
 // backend defines the target API backend
 // for Vault. It must include each path
 // and the secrets it will store.
 func newBackend() *ccloudBackend {
-	var b = &ccloudBackend{}
+	var b = &ccloudBackend{
+		clients:     make(map[string]*ccloudAPIKeyClient),
+		roleLocks:   locksutil.CreateLocks(),
+		poolRefills: make(chan string, poolRefillQueueSize),
+	}
+	b.poolWorkerCtx, b.stopPoolWorker = context.WithCancel(context.Background())
 
 	b.Backend = &framework.Backend{
 		Help: strings.TrimSpace(backendHelp),
 		Paths: framework.PathAppend(
 			pathRole(b),
+			pathStaticRoles(b),
+			pathLibrary(b),
+			pathLibraryCheckOut(b),
 			[]*framework.Path{
+				pathConfigRotateRoot(b),
 				pathConfig(b),
+				pathConfigList(b),
 				pathCredentials(b),
+				pathStaticCredentials(b),
 			},
 		),
 		PathsSpecial: &logical.Paths{
 			LocalStorage: []string{},
 			SealWrapStorage: []string{
 				"config",
+				"config/*",
 				"role/*",
+				staticRoleStoragePrefix + "*",
+				libraryStoragePrefix + "*",
+				poolStoragePrefix + "*",
 			},
 		},
 		Secrets: []*framework.Secret{
 			b.ccloudClusterApiKey(),
+			b.ccloudLibraryKey(),
 		},
-		BackendType: logical.TypeLogical,
-		Invalidate:  b.invalidate,
+		BackendType:    logical.TypeLogical,
+		Invalidate:     b.invalidate,
+		PeriodicFunc:   b.periodicFunc,
+		InitializeFunc: b.initialize,
+		Clean:          b.clean,
 	}
 	return b
 }
 
-// reset clears any client configuration for a new
+// reset clears the clients of all connections for a new
 // backend to be configured
 func (b *ccloudBackend) reset() {
 	b.lock.Lock()
 	defer b.lock.Unlock()
-	b.client = nil
+	b.clients = make(map[string]*ccloudAPIKeyClient)
+}
+
+// resetClient clears the client of a single connection
+func (b *ccloudBackend) resetClient(connection string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.clients, normalizeConnection(connection))
 }
 
-// invalidate clears an existing client configuration in
-// the backend
+// setClient caches the client of a connection
+func (b *ccloudBackend) setClient(connection string, client *ccloudAPIKeyClient) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.clients[normalizeConnection(connection)] = client
+}
+
+// invalidate clears the client of the connection whose
+// configuration changed
 func (b *ccloudBackend) invalidate(ctx context.Context, key string) {
-	if key == "config" {
-		b.reset()
+	if connection, ok := connectionFromStoragePath(key); ok {
+		b.resetClient(connection)
+	}
+}
+
+// periodicFunc is invoked by Vault about once a minute. It rotates the root
+// credentials and the keys of static roles that are due, and schedules the
+// key pools of roles to be refilled. It only runs where storage is writable.
+func (b *ccloudBackend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	if !b.writesStorage() {
+		return nil
+	}
+
+	connections, err := listConnections(ctx, req.Storage)
+	if err != nil {
+		return err
 	}
+
+	var errs []error
+	for _, connection := range connections {
+		if err := b.rotateRootIfDue(ctx, req.Storage, connection); err != nil {
+			errs = append(errs, fmt.Errorf("connection %q: %w", connection, err))
+		}
+	}
+
+	if err := b.rotateStaticRolesIfDue(ctx, req.Storage); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := b.schedulePoolRefills(ctx, req.Storage); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// writesStorage reports whether this node writes the mount's storage, which
+// replicated and standby nodes leave to the primary
+func (b *ccloudBackend) writesStorage() bool {
+	replicationState := b.System().ReplicationState()
+	if !b.System().LocalMount() && replicationState.HasState(consts.ReplicationPerformanceSecondary) {
+		return false
+	}
+	return !replicationState.HasState(consts.ReplicationDRSecondary | consts.ReplicationPerformanceStandby)
 }
 
 // getClient locks the backend as it configures and creates a
 // a new client for the target API
-func (b *ccloudBackend) getClientCached(ctx context.Context, s logical.Storage) *ccloudAPIKeyClient {
+func (b *ccloudBackend) getClientCached(ctx context.Context, s logical.Storage, connection string) *ccloudAPIKeyClient {
 	b.lock.RLock()
 	defer b.lock.RUnlock()
 
-	return b.client
+	return b.clients[connection]
 }
 
-func (b *ccloudBackend) getClient(ctx context.Context, s logical.Storage) (*ccloudAPIKeyClient, error) {
-	client := b.getClientCached(ctx, s)
+func (b *ccloudBackend) getClient(ctx context.Context, s logical.Storage, connection string) (*ccloudAPIKeyClient, error) {
+	connection = normalizeConnection(connection)
+
+	client := b.getClientCached(ctx, s, connection)
 	if client != nil {
 		return client, nil
 	}
@@ -93,26 +197,34 @@ func (b *ccloudBackend) getClient(ctx context.Context, s logical.Storage) (*cclo
 	defer b.lock.Unlock()
 
 	// Check for a race
-	if b.client != nil {
-		return b.client, nil
+	if client := b.clients[connection]; client != nil {
+		return client, nil
 	}
 
 	// Build a new client
-	config, err := getConfig(ctx, s)
+	config, err := getConfig(ctx, s, connection)
 	if err != nil {
 		return nil, err
 	}
 
-	client, err = newClient(config, b.Logger())
+	client, err = b.buildClient(config)
 	if err != nil {
 		return nil, err
 	}
 
-	b.client = client
+	b.clients[connection] = client
 
 	return client, nil
 }
 
+// buildClient creates a client for the configured authentication method
+func (b *ccloudBackend) buildClient(config *ccloudConfig) (*ccloudAPIKeyClient, error) {
+	if config.usesWorkloadIdentity() {
+		return newWorkloadIdentityClient(config, b.Logger(), b.System())
+	}
+	return newClient(config, b.Logger())
+}
+
 // backendHelp should contain help information for the backend
 const backendHelp = `
 The Confluent Cloud secrets backend dynamically generates CCloud Cluster API
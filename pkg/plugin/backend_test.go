@@ -2,12 +2,23 @@ package plugin
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/require"
-	"os"
-	"testing"
 )
 
 const (
@@ -22,19 +33,584 @@ const (
 // getTestBackend will help you construct a test backend object. Update this function with your target backend.
 func getTestBackend(testingBackground testing.TB) (*ccloudBackend, logical.Storage) {
 	testingBackground.Helper()
+
+	b, s := newTestBackend(testingBackground, logical.TestSystemView())
+	if err := b.Initialize(context.Background(), &logical.InitializationRequest{Storage: s}); err != nil {
+		testingBackground.Fatal(err)
+	}
+
+	return b, s
+}
+
+// newTestBackend creates a backend with a system view, without initializing
+// it as Vault does before the backend serves requests
+func newTestBackend(testingBackground testing.TB, system logical.SystemView) (*ccloudBackend, logical.Storage) {
+	testingBackground.Helper()
 	config := logical.TestBackendConfig()
 	config.StorageView = new(logical.InmemStorage)
 	config.Logger = hclog.NewNullLogger()
-	config.System = logical.TestSystemView()
+	config.System = system
 
 	factoryBackground, err := Factory(context.Background(), config)
 	if err != nil {
 		testingBackground.Fatal(err)
 	}
+	testingBackground.Cleanup(func() {
+		factoryBackground.Cleanup(context.Background())
+	})
 
 	return factoryBackground.(*ccloudBackend), config.StorageView
 }
 
+const (
+	fakeRootKeyId     = "ROOTKEY"
+	fakeRootKeySecret = "ROOTSECRET"
+	fakeRootKeyOwner  = "sa-root"
+
+	fakeIdentityPoolId = "pool-1"
+	fakeSubjectToken   = "idp-token"
+)
+
+// fakeCCloudKey is an API key held by fakeCCloud
+type fakeCCloudKey struct {
+	Secret      string
+	Owner       string
+	Resource    string
+	DisplayName string
+	Description string
+	CreatedAt   time.Time
+	// rejections is how many more times the key fails to authenticate,
+	// like a key that has not propagated yet
+	rejections int
+}
+
+// fakeRoleBinding is an RBAC role binding held by fakeCCloud
+type fakeRoleBinding struct {
+	Principal  string `json:"principal"`
+	RoleName   string `json:"role_name"`
+	CRNPattern string `json:"crn_pattern"`
+}
+
+// fakeFailure is a failure the fake injects instead of handling a request.
+// A status of 0 drops the connection without a response.
+type fakeFailure struct {
+	status     int
+	retryAfter string
+	// afterHandling handles the request before failing, like a server that
+	// fails after committing a change
+	afterHandling bool
+	// path limits the failure to requests for the given path
+	path string
+	// skip lets that many matching requests through before failing
+	skip int
+}
+
+// fakeCCloud is an in-memory stand-in for the Confluent Cloud API used by
+// unit tests. It is seeded with a root key that is allowed to call it.
+type fakeCCloud struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	keys map[string]fakeCCloudKey
+	next int
+
+	// nextAccount numbers the service accounts created through the fake
+	nextAccount int
+
+	roleBindings map[string]fakeRoleBinding
+	nextBinding  int
+
+	// accessTokens holds the access tokens issued by the token exchange
+	accessTokens map[string]bool
+	exchanges    int
+
+	// forbidden rejects all authenticated calls with 403 Forbidden
+	forbidden bool
+
+	// newKeyRejections is how many times keys created through the fake fail
+	// to authenticate before they are accepted
+	newKeyRejections int
+
+	// failures holds the failures to inject, by HTTP method, and requests
+	// counts the authenticated requests, by HTTP method
+	failures map[string][]fakeFailure
+	requests map[string]int
+
+	// objects holds the paths of other objects that can be read, such as
+	// service accounts and clusters, with the environment they belong to
+	objects map[string]string
+	// specs holds the spec returned for some of the objects
+	specs map[string]map[string]interface{}
+}
+
+func newFakeCCloud(t testing.TB) *fakeCCloud {
+	t.Helper()
+
+	f := &fakeCCloud{
+		keys: map[string]fakeCCloudKey{
+			fakeRootKeyId: {Secret: fakeRootKeySecret, Owner: fakeRootKeyOwner},
+		},
+		accessTokens: map[string]bool{},
+		roleBindings: map[string]fakeRoleBinding{},
+		specs:        map[string]map[string]interface{}{},
+		failures:     map[string][]fakeFailure{},
+		requests:     map[string]int{},
+		objects: map[string]string{
+			serviceAccountsPath + "/" + fakeRootKeyOwner: "",
+		},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+// configure writes a backend config pointing at the fake with the root key
+func (f *fakeCCloud) configure(t testing.TB, b *ccloudBackend, s logical.Storage) {
+	t.Helper()
+	f.configureConnection(t, b, s, defaultConnectionName)
+}
+
+// configureConnection writes the config of a named connection pointing at
+// the fake with the root key
+func (f *fakeCCloud) configureConnection(t testing.TB, b *ccloudBackend, s logical.Storage, connection string) {
+	t.Helper()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      connectionStoragePath(connection),
+		Data: map[string]interface{}{
+			"ccloud_api_key_id":     fakeRootKeyId,
+			"ccloud_api_key_secret": fakeRootKeySecret,
+			"url":                   f.URL,
+		},
+		Storage: s,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError(), "unexpected error response: %v", resp.Error())
+}
+
+func (f *fakeCCloud) hasKey(id string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, ok := f.keys[id]
+	return ok
+}
+
+func (f *fakeCCloud) key(id string) fakeCCloudKey {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.keys[id]
+}
+
+func (f *fakeCCloud) keyCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.keys)
+}
+
+// delayNewKeys makes keys created from now on fail to authenticate the
+// given number of times
+func (f *fakeCCloud) delayNewKeys(rejections int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.newKeyRejections = rejections
+}
+
+func (f *fakeCCloud) setForbidden(forbidden bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.forbidden = forbidden
+}
+
+// failNext makes the next requests with the given method fail
+func (f *fakeCCloud) failNext(method string, failures ...fakeFailure) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures[method] = append(f.failures[method], failures...)
+}
+
+// addObject makes an object readable at the given path, in the given
+// environment if it is env-scoped
+func (f *fakeCCloud) addObject(path, environment string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.objects[path] = environment
+}
+
+// addKafkaCluster makes a Kafka cluster with a REST endpoint readable
+func (f *fakeCCloud) addKafkaCluster(id, environment, endpoint string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.objects[kafkaClustersPath+"/"+id] = environment
+	f.specs[kafkaClustersPath+"/"+id] = map[string]interface{}{"http_endpoint": endpoint}
+}
+
+// hasObject reports whether an object exists at the given path
+func (f *fakeCCloud) hasObject(path string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, found := f.objects[path]
+	return found
+}
+
+// bindings returns the role bindings of a principal
+func (f *fakeCCloud) bindings(principal string) []fakeRoleBinding {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var bindings []fakeRoleBinding
+	for _, binding := range f.roleBindings {
+		if binding.Principal == principal {
+			bindings = append(bindings, binding)
+		}
+	}
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].RoleName < bindings[j].RoleName
+	})
+	return bindings
+}
+
+func (f *fakeCCloud) requestCount(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.requests[method]
+}
+
+func (f *fakeCCloud) tokenExchanges() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.exchanges
+}
+
+func (f *fakeCCloud) authorized(r *http.Request) bool {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return f.accessTokens[token]
+	}
+
+	user, pass, ok := r.BasicAuth()
+	key, found := f.keys[user]
+	if !ok || !found || key.Secret != pass {
+		return false
+	}
+
+	if key.rejections > 0 {
+		key.rejections--
+		f.keys[user] = key
+		return false
+	}
+
+	return true
+}
+
+func (f *fakeCCloud) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == stsTokenPath {
+		f.serveTokenExchange(w, r)
+		return
+	}
+
+	if !f.authorized(r) {
+		f.writeError(w, http.StatusUnauthorized, "invalid API key")
+		return
+	}
+
+	if f.forbidden {
+		f.writeError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	f.requests[r.Method]++
+	failures := f.failures[r.Method]
+	if len(failures) > 0 && (failures[0].path == "" || failures[0].path == r.URL.Path) {
+		if failures[0].skip > 0 {
+			failures[0].skip--
+			f.serveAPIKeys(w, r)
+			return
+		}
+
+		failure := failures[0]
+		f.failures[r.Method] = failures[1:]
+
+		if failure.afterHandling {
+			f.serveAPIKeys(httptest.NewRecorder(), r)
+		}
+		f.writeFailure(w, failure)
+		return
+	}
+
+	f.serveAPIKeys(w, r)
+}
+
+func (f *fakeCCloud) serveAPIKeys(w http.ResponseWriter, r *http.Request) {
+	const apiKeysPath = "/iam/v2/api-keys"
+	switch {
+	case r.URL.Path == apiKeysPath && r.Method == http.MethodGet:
+		owner := r.URL.Query().Get("spec.owner")
+		resource := r.URL.Query().Get("spec.resource")
+
+		ids := make([]string, 0, len(f.keys))
+		for id, key := range f.keys {
+			if (owner == "" || key.Owner == owner) && (resource == "" || key.Resource == resource) {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+
+		data := make([]interface{}, 0, len(ids))
+		for _, id := range ids {
+			data = append(data, f.keyJSON(id, f.keys[id], false))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": data,
+		})
+	case r.URL.Path == apiKeysPath && r.Method == http.MethodPost:
+		var req struct {
+			Spec struct {
+				Owner       struct{ Id string }  `json:"owner"`
+				Resource    *struct{ Id string } `json:"resource"`
+				DisplayName string               `json:"display_name"`
+				Description string               `json:"description"`
+			} `json:"spec"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			f.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		f.next++
+		id := fmt.Sprintf("KEY%d", f.next)
+		key := fakeCCloudKey{
+			Secret:      fmt.Sprintf("SECRET%d", f.next),
+			Owner:       req.Spec.Owner.Id,
+			DisplayName: req.Spec.DisplayName,
+			Description: req.Spec.Description,
+			CreatedAt:   time.Now(),
+			rejections:  f.newKeyRejections,
+		}
+		if req.Spec.Resource != nil {
+			key.Resource = req.Spec.Resource.Id
+		}
+		f.keys[id] = key
+		f.writeKey(w, http.StatusAccepted, id, key, true)
+	case strings.HasPrefix(r.URL.Path, apiKeysPath+"/"):
+		id := strings.TrimPrefix(r.URL.Path, apiKeysPath+"/")
+		key, found := f.keys[id]
+		if !found {
+			f.writeError(w, http.StatusNotFound, "API key not found")
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			f.writeKey(w, http.StatusOK, id, key, false)
+		case http.MethodDelete:
+			delete(f.keys, id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	case r.URL.Path == roleBindingsPath && r.Method == http.MethodPost:
+		var binding fakeRoleBinding
+		if err := json.NewDecoder(r.Body).Decode(&binding); err != nil {
+			f.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// like Confluent Cloud, the same binding cannot be created twice
+		for _, existing := range f.roleBindings {
+			if existing == binding {
+				f.writeError(w, http.StatusConflict, "role binding already exists")
+				return
+			}
+		}
+
+		f.nextBinding++
+		id := fmt.Sprintf("rb-%d", f.nextBinding)
+		f.roleBindings[id] = binding
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id": id,
+		})
+	case strings.HasPrefix(r.URL.Path, roleBindingsPath+"/") && r.Method == http.MethodDelete:
+		id := strings.TrimPrefix(r.URL.Path, roleBindingsPath+"/")
+		if _, found := f.roleBindings[id]; !found {
+			f.writeError(w, http.StatusNotFound, "role binding not found")
+			return
+		}
+		delete(f.roleBindings, id)
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == serviceAccountsPath && r.Method == http.MethodPost:
+		f.nextAccount++
+		id := fmt.Sprintf("sa-dyn%d", f.nextAccount)
+		f.objects[serviceAccountsPath+"/"+id] = ""
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id": id,
+		})
+	case strings.HasPrefix(r.URL.Path, serviceAccountsPath+"/") && r.Method == http.MethodDelete:
+		if _, found := f.objects[r.URL.Path]; !found {
+			f.writeError(w, http.StatusNotFound, "service account not found")
+			return
+		}
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		environment, found := f.objects[r.URL.Path]
+		if !found || r.Method != http.MethodGet || environment != r.URL.Query().Get("environment") {
+			f.writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+
+		object := map[string]interface{}{
+			"id": path.Base(r.URL.Path),
+		}
+		if spec, found := f.specs[r.URL.Path]; found {
+			object["spec"] = spec
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(object)
+	}
+}
+
+// serveTokenExchange exchanges fakeSubjectToken for a new access token
+func (f *fakeCCloud) serveTokenExchange(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		f.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != tokenExchangeGrantType ||
+		r.PostForm.Get("subject_token") != fakeSubjectToken ||
+		r.PostForm.Get("identity_pool_id") != fakeIdentityPoolId {
+		f.writeError(w, http.StatusUnauthorized, "invalid subject token")
+		return
+	}
+
+	f.exchanges++
+	token := fmt.Sprintf("access-token-%d", f.exchanges)
+	f.accessTokens[token] = true
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token":      token,
+		"issued_token_type": accessTokenType,
+		"token_type":        "Bearer",
+		"expires_in":        3600,
+	})
+}
+
+func (f *fakeCCloud) writeKey(w http.ResponseWriter, status int, id string, key fakeCCloudKey, withSecret bool) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(f.keyJSON(id, key, withSecret))
+}
+
+func (f *fakeCCloud) keyJSON(id string, key fakeCCloudKey, withSecret bool) map[string]interface{} {
+	spec := map[string]interface{}{
+		"owner":        map[string]interface{}{"id": key.Owner},
+		"display_name": key.DisplayName,
+		"description":  key.Description,
+	}
+	if withSecret {
+		spec["secret"] = key.Secret
+	}
+	if key.Resource != "" {
+		spec["resource"] = map[string]interface{}{"id": key.Resource}
+	}
+
+	keyJSON := map[string]interface{}{
+		"id":   id,
+		"spec": spec,
+	}
+	if !key.CreatedAt.IsZero() {
+		keyJSON["metadata"] = map[string]interface{}{"created_at": key.CreatedAt.Format(time.RFC3339)}
+	}
+	return keyJSON
+}
+
+func (f *fakeCCloud) writeFailure(w http.ResponseWriter, failure fakeFailure) {
+	if failure.status == 0 {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+		return
+	}
+
+	if failure.retryAfter != "" {
+		w.Header().Set("Retry-After", failure.retryAfter)
+	}
+	f.writeError(w, failure.status, http.StatusText(failure.status))
+}
+
+func (f *fakeCCloud) writeError(w http.ResponseWriter, status int, detail string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]interface{}{
+			{"status": fmt.Sprint(status), "detail": detail},
+		},
+	})
+}
+
+// requireNoSecrets fails if any of the secrets appears anywhere in the
+// response data
+func requireNoSecrets(t testing.TB, resp *logical.Response, secrets ...string) {
+	t.Helper()
+
+	require.NotNil(t, resp)
+	encoded, err := json.Marshal(resp.Data)
+	require.NoError(t, err)
+
+	for _, secret := range secrets {
+		require.NotEmpty(t, secret)
+		require.NotContains(t, string(encoded), secret)
+	}
+}
+
+func TestInvalidateOnlyResetsChangedConnection(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+	fake.configureConnection(t, b, s, "other")
+
+	defaultClient, err := b.getClient(context.Background(), s, defaultConnectionName)
+	require.NoError(t, err)
+	otherClient, err := b.getClient(context.Background(), s, "other")
+	require.NoError(t, err)
+	require.NotSame(t, defaultClient, otherClient)
+
+	b.invalidate(context.Background(), "config/other")
+
+	require.Same(t, defaultClient, b.getClientCached(context.Background(), s, defaultConnectionName))
+	require.Nil(t, b.getClientCached(context.Background(), s, "other"))
+
+	b.invalidate(context.Background(), configStoragePath)
+
+	require.Nil(t, b.getClientCached(context.Background(), s, defaultConnectionName))
+}
+
 // runAcceptanceTests will separate unit tests from
 // acceptance tests, which will make active requests
 // to your target API.
@@ -521,3 +1097,144 @@ func (testEnv *testEnv) ReadMultiUseKey(testing *testing.T) {
 		Storage:   testEnv.Storage,
 	}, keyId.(string))
 }
+
+// Kafka API key of the cluster that fakeKafkaREST accepts at first
+const (
+	fakeKafkaAdminKeyId     = "KAFKAADMINKEY"
+	fakeKafkaAdminKeySecret = "kafka-admin-secret"
+)
+
+// fakeKafkaREST is an in-memory stand-in for the ACL API of a Kafka
+// cluster's REST v3 endpoint. Like Kafka REST, it only accepts a Kafka API
+// key of the cluster.
+type fakeKafkaREST struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	clusterId string
+	acls      map[fakeKafkaACL]bool
+	// adminKeyId and adminKeySecret are the Kafka API key it accepts
+	adminKeyId     string
+	adminKeySecret string
+	// callers are the users of the requests received, by method
+	callers map[string][]string
+}
+
+// fakeKafkaACL is an ACL held by fakeKafkaREST
+type fakeKafkaACL struct {
+	Principal string
+	kafkaACL
+}
+
+func newFakeKafkaREST(t testing.TB, clusterId string) *fakeKafkaREST {
+	t.Helper()
+
+	f := &fakeKafkaREST{
+		clusterId: clusterId,
+		acls:      map[fakeKafkaACL]bool{},
+		callers:   map[string][]string{},
+
+		adminKeyId:     fakeKafkaAdminKeyId,
+		adminKeySecret: fakeKafkaAdminKeySecret,
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+func (f *fakeKafkaREST) addACL(principal string, acl kafkaACL) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.acls[fakeKafkaACL{Principal: principal, kafkaACL: acl}] = true
+}
+
+// aclsOf returns the ACLs of a principal
+func (f *fakeKafkaREST) aclsOf(principal string) []kafkaACL {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var acls []kafkaACL
+	for acl := range f.acls {
+		if acl.Principal == principal {
+			acls = append(acls, acl.kafkaACL)
+		}
+	}
+	sort.Slice(acls, func(i, j int) bool {
+		return acls[i].Operation < acls[j].Operation
+	})
+	return acls
+}
+
+// rotateAdminKey replaces the Kafka API key the cluster accepts
+func (f *fakeKafkaREST) rotateAdminKey(keyId, secret string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.adminKeyId, f.adminKeySecret = keyId, secret
+}
+
+// callersOf returns the users that made requests with a method
+func (f *fakeKafkaREST) callersOf(method string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.callers[method])
+}
+
+func (f *fakeKafkaREST) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	user, password, ok := r.BasicAuth()
+	if ok {
+		f.callers[r.Method] = append(f.callers[r.Method], user)
+	}
+	if user != f.adminKeyId || password != f.adminKeySecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path != kafkaACLsPath(f.clusterId) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var acl struct {
+			Principal string `json:"principal"`
+			kafkaACL
+		}
+		if err := json.NewDecoder(r.Body).Decode(&acl); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.acls[fakeKafkaACL{Principal: acl.Principal, kafkaACL: acl.kafkaACL}] = true
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		query := r.URL.Query()
+		acl := fakeKafkaACL{
+			Principal: query.Get("principal"),
+			kafkaACL: kafkaACL{
+				ResourceType: query.Get("resource_type"),
+				ResourceName: query.Get("resource_name"),
+				PatternType:  query.Get("pattern_type"),
+				Operation:    query.Get("operation"),
+				Permission:   query.Get("permission"),
+				Host:         query.Get("host"),
+			},
+		}
+
+		deleted := []interface{}{}
+		if f.acls[acl] {
+			delete(f.acls, acl)
+			deleted = append(deleted, acl)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": deleted})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -17,6 +19,16 @@ type ccloudClusterApiKey struct {
 	KeyId      string `json:"key_id"`
 	Secret     string `json:"secret"`
 	UsageCount int    `json:"usage_count"`
+
+	// ServiceAccountId is the service account created to own the key, if
+	// the role uses dynamic service accounts
+	ServiceAccountId string `json:"service_account_id,omitempty"`
+
+	// RoleBindingIds are the role bindings created on the owner for the key
+	RoleBindingIds []string `json:"role_binding_ids,omitempty"`
+
+	// ACLs are the Kafka ACLs created for the owner of the key
+	ACLs *leaseACLs `json:"acls,omitempty"`
 }
 
 // ccloudClusterApiKey defines a secret to store for a given role
@@ -46,7 +58,19 @@ func (b *ccloudBackend) decreaseCountAndDeleteKey(roleName string, role *apikeyR
 
 // tokenRevoke removes the token from the Vault storage API and calls the client to revoke the token
 func (b *ccloudBackend) tokenRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	client, err := b.getClient(ctx, req.Storage)
+	// leases issued before connections were introduced belong to the
+	// default connection
+	connection := defaultConnectionName
+	if req.Secret != nil {
+		if connectionRaw, ok := req.Secret.InternalData["connection"]; ok {
+			connection, ok = connectionRaw.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid value for connection in secret internal data")
+			}
+		}
+	}
+
+	client, err := b.getClient(ctx, req.Storage, connection)
 	if err != nil {
 		return nil, fmt.Errorf("error getting client: %w", err)
 	}
@@ -59,29 +83,97 @@ func (b *ccloudBackend) tokenRevoke(ctx context.Context, req *logical.Request, d
 		}
 	}
 
-	var roleName = req.Secret.InternalData["role"].(string)
-	var role, _ = b.getRole(ctx, req.Storage, roleName)
+	roleName, _ := req.Secret.InternalData["role"].(string)
+	leaseRef, _ := req.Secret.InternalData["lease_ref"].(string)
 
-	if role.MultiUseKey {
-		role.UsageCount--
+	// a shared key is deleted once none of its leases use it anymore
+	unused, err := b.releaseRoleKey(ctx, req.Storage, roleName, keyId, leaseRef)
+	if err != nil {
+		return nil, err
+	}
 
-		if role.UsageCount == 0 {
-			role.CCKeyId = ""
-			role.CCKeySecret = ""
+	serviceAccountId, _ := req.Secret.InternalData["service_account_id"].(string)
+
+	acls, err := leaseACLsFromInternalData(req.Secret.InternalData)
+	if err != nil {
+		return nil, err
+	}
+	if acls != nil && len(acls.ACLs) > 0 {
+		// ACLs are deleted with the role's current Kafka API key, so the key
+		// can be rotated without storing it in every lease
+		role, err := b.getRole(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving role: %w", err)
+		}
+		if role == nil || role.ACLApiKeyId == "" {
+			return nil, fmt.Errorf("error revoking Kafka ACLs: role %q has no acl_api_key_id to delete them with", roleName)
+		}
+
+		for _, acl := range acls.ACLs {
+			if err := client.DeleteKafkaACL(ctx, acls.Endpoint, acls.ClusterId, role.kafkaACLAuth(), acls.Principal, acl); err != nil {
+				return nil, fmt.Errorf("error revoking Kafka ACL: %w", err)
+			}
 		}
-		setRole(ctx, req.Storage, roleName, role)
 	}
 
-	if !role.MultiUseKey || role.UsageCount == 0 {
+	bindingIds, err := roleBindingIds(req.Secret.InternalData)
+	if err != nil {
+		return nil, err
+	}
+	for _, bindingId := range bindingIds {
+		if err := client.DeleteRoleBinding(ctx, bindingId); err != nil {
+			return nil, fmt.Errorf("error revoking role binding: %w", err)
+		}
+	}
+
+	if unused {
 		if err := client.DeleteApiKey(ctx, keyId); err != nil {
-			return nil, fmt.Errorf("error revoking user token: %w", err)
+			// a key that is gone was deleted by an earlier attempt to
+			// revoke the lease, which failed to delete its service account,
+			// or, if it is shared, by the revocation of its last lease
+			if (serviceAccountId == "" && leaseRef == "") || !errors.Is(err, errCCloudNotFound) {
+				return nil, fmt.Errorf("error revoking user token: %w", err)
+			}
 		}
 		b.Logger().Info("Deleting CC API key: %v", keyId)
 	}
 
+	if serviceAccountId != "" {
+		if err := client.DeleteServiceAccount(ctx, serviceAccountId); err != nil {
+			return nil, fmt.Errorf("error revoking service account: %w", err)
+		}
+		b.Logger().Info("deleted dynamic service account", "service_account_id", serviceAccountId)
+	}
+
 	return nil, nil
 }
 
+// releaseRoleKey ends the use of a key by a lease of a role and reports
+// whether no lease uses the key anymore. Keys that are not shared are used by
+// a single lease.
+func (b *ccloudBackend) releaseRoleKey(ctx context.Context, s logical.Storage, roleName, keyId, leaseRef string) (bool, error) {
+	lock := locksutil.LockForKey(b.roleLocks, roleName)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := b.getRole(ctx, s, roleName)
+	if err != nil {
+		return false, fmt.Errorf("error retrieving role: %w", err)
+	}
+	if role == nil {
+		return true, nil
+	}
+
+	shared, unused := role.releaseSharedKey(keyId, leaseRef)
+	if shared {
+		if err := setRole(ctx, s, roleName, role); err != nil {
+			return false, fmt.Errorf("error storing shared key usage: %w", err)
+		}
+	}
+
+	return unused, nil
+}
+
 // tokenRenew calls the client to create a new token and stores it in the Vault storage API
 func (b *ccloudBackend) tokenRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	roleRaw, ok := req.Secret.InternalData["role"]
@@ -4,14 +4,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
 
 	apikeys "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
 	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
 )
 
 type ccloudAPIKeyClient struct {
-	client    *apikeys.APIClient
-	authBasic *apikeys.BasicAuth
+	client      *apikeys.APIClient
+	httpClient  *http.Client
+	baseURL     string
+	authBasic   *apikeys.BasicAuth
+	tokenSource *ccloudTokenSource
+	retry       retryPolicy
+	limiter     *ccloudLimiter
 
 	log hclog.Logger
 }
@@ -21,6 +31,10 @@ func newClient(config *ccloudConfig, logger hclog.Logger) (*ccloudAPIKeyClient,
 		return nil, errors.New("Client configuration nil")
 	}
 
+	if config.usesOAuth() {
+		return newOAuthClient(config, logger)
+	}
+
 	if config.ApiKeyId == "" {
 		return nil, errors.New("CCloud API Key ID not defined")
 	}
@@ -33,11 +47,110 @@ func newClient(config *ccloudConfig, logger hclog.Logger) (*ccloudAPIKeyClient,
 		return nil, errors.New("CCloud API Key Secret not defined")
 	}
 
+	httpClient, err := newHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ccloudAPIKeyClient{
+		client:     newAPIClient(config, httpClient),
+		httpClient: httpClient,
+		baseURL:    strings.TrimSuffix(config.URL, "/"),
+		authBasic: &apikeys.BasicAuth{
+			UserName: config.ApiKeyId,
+			Password: config.ApiKeySecret,
+		},
+
+		retry:   newRetryPolicy(config),
+		limiter: newLimiter(config),
+
+		log: loggerOrNull(logger),
+	}, nil
+}
+
+// newOAuthClient creates a client that authenticates with access tokens
+// obtained through the OAuth client credentials flow
+func newOAuthClient(config *ccloudConfig, logger hclog.Logger) (*ccloudAPIKeyClient, error) {
+	if config.OAuthClientId == "" {
+		return nil, errors.New("OAuth client ID not defined")
+	}
+
+	if config.OAuthClientSecret == "" {
+		return nil, errors.New("OAuth client secret not defined")
+	}
+
+	if config.IdentityPoolId == "" {
+		return nil, errors.New("identity pool ID not defined")
+	}
+
+	if config.URL == "" {
+		return nil, errors.New("CCloud URL not defined")
+	}
+
+	httpClient, err := newHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ccloudAPIKeyClient{
+		client:     newAPIClient(config, httpClient),
+		httpClient: httpClient,
+		baseURL:    strings.TrimSuffix(config.URL, "/"),
+		tokenSource: newTokenSource(httpClient, config.stsURL(), config.IdentityPoolId,
+			clientCredentialsSubjectToken(httpClient, config)),
+
+		retry:   newRetryPolicy(config),
+		limiter: newLimiter(config),
+
+		log: loggerOrNull(logger),
+	}, nil
+}
+
+// newWorkloadIdentityClient creates a client that authenticates with access
+// tokens obtained for plugin identity tokens signed by Vault
+func newWorkloadIdentityClient(config *ccloudConfig, logger hclog.Logger, system logical.SystemView) (*ccloudAPIKeyClient, error) {
+	if config == nil {
+		return nil, errors.New("Client configuration nil")
+	}
+
+	if config.IdentityPoolId == "" {
+		return nil, errors.New("identity pool ID not defined")
+	}
+
+	if config.URL == "" {
+		return nil, errors.New("CCloud URL not defined")
+	}
+
+	httpClient, err := newHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ccloudAPIKeyClient{
+		client:     newAPIClient(config, httpClient),
+		httpClient: httpClient,
+		baseURL:    strings.TrimSuffix(config.URL, "/"),
+		tokenSource: newTokenSource(httpClient, config.stsURL(), config.IdentityPoolId,
+			pluginIdentitySubjectToken(system, config)),
+
+		retry:   newRetryPolicy(config),
+		limiter: newLimiter(config),
+
+		log: loggerOrNull(logger),
+	}, nil
+}
+
+func loggerOrNull(logger hclog.Logger) hclog.Logger {
 	if logger == nil {
-		logger = hclog.NewNullLogger()
+		return hclog.NewNullLogger()
 	}
+	return logger
+}
 
+// newAPIClient creates the API keys client for the configured URL
+func newAPIClient(config *ccloudConfig, httpClient *http.Client) *apikeys.APIClient {
 	apikeysConfig := apikeys.NewConfiguration()
+	apikeysConfig.HTTPClient = httpClient
 
 	if config.URL != "" {
 		// Prepend custom URL, making it the new default.
@@ -50,25 +163,36 @@ func newClient(config *ccloudConfig, logger hclog.Logger) (*ccloudAPIKeyClient,
 		)
 	}
 
-	client := apikeys.NewAPIClient(apikeysConfig)
-
-	return &ccloudAPIKeyClient{
-		client: client,
-		authBasic: &apikeys.BasicAuth{
-			UserName: config.ApiKeyId,
-			Password: config.ApiKeySecret,
-		},
-
-		log: logger,
-	}, nil
+	return apikeys.NewAPIClient(apikeysConfig)
 }
 
-func (c *ccloudAPIKeyClient) contextWithAuth(ctx context.Context) context.Context {
+func (c *ccloudAPIKeyClient) contextWithAuth(ctx context.Context) (context.Context, error) {
+	if c.tokenSource != nil {
+		token, err := c.tokenSource.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting CCloud access token: %w", err)
+		}
+		return context.WithValue(ctx, apikeys.ContextAccessToken, token), nil
+	}
+
 	if c.authBasic != nil {
 		ctx = context.WithValue(ctx, apikeys.ContextBasicAuth, *c.authBasic)
 	}
 
-	return ctx
+	return ctx, nil
+}
+
+// execute makes a single call to Confluent Cloud within the limits of the
+// local rate limiter, classifying the error response if it fails
+func (c *ccloudAPIKeyClient) execute(ctx context.Context, call func() (*http.Response, error)) (*http.Response, error) {
+	release, err := c.limiter.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	resp, err := call()
+	return resp, newAPIError(resp, err)
 }
 
 func (c *ccloudAPIKeyClient) CreateApiKey(
@@ -77,7 +201,10 @@ func (c *ccloudAPIKeyClient) CreateApiKey(
 	resource, resourceEnv string,
 	displayName, description string,
 ) (keyId, keySecret string, err error) {
-	ctx = c.contextWithAuth(ctx)
+	ctx, err = c.contextWithAuth(ctx)
+	if err != nil {
+		return "", "", err
+	}
 
 	v2ApiKey := apikeys.IamV2ApiKey{
 		Spec: &apikeys.IamV2ApiKeySpec{
@@ -104,13 +231,36 @@ func (c *ccloudAPIKeyClient) CreateApiKey(
 		v2ApiKey.Spec.Description = &description
 	}
 
+	spec := *v2ApiKey.Spec
 	req := c.client.APIKeysIamV2Api.CreateIamV2ApiKey(ctx).IamV2ApiKey(v2ApiKey)
-	v2ApiKey, _, err = req.Execute()
 
-	if err != nil {
-		if openAPIErr, ok := err.(apikeys.GenericOpenAPIError); ok {
-			return "", "", fmt.Errorf("error creating CCloud Cluster API Key: %w. Ccloud response: %s", err, string(openAPIErr.Body()))
+	var attemptStart time.Time
+	err = c.retry.do(ctx, c.log, "create API key", func() (*http.Response, error) {
+		return c.execute(ctx, func() (resp *http.Response, err error) {
+			attemptStart = time.Now()
+			v2ApiKey, resp, err = req.Execute()
+			return resp, err
+		})
+	}, func(resp *http.Response, err error) error {
+		// the key may have been created even though the call failed, and a
+		// retry would leave it behind without anyone knowing its secret
+		if !isAmbiguous(resp) {
+			return nil
+		}
+
+		created, listErr := c.findApiKeys(ctx, spec, attemptStart.Add(-createdAtSkew))
+		if listErr != nil {
+			return fmt.Errorf("%w (not retried: unable to check whether the API key was created: %v)", err, listErr)
 		}
+		if len(created) > 0 {
+			c.log.Warn("CCloud API key may have been created by a failed call", "owner", owner, "keys", created)
+			return fmt.Errorf("%w (not retried: API keys %s may have been created by this call)", err, strings.Join(created, ", "))
+		}
+
+		return nil
+	})
+
+	if err != nil {
 		return "", "", fmt.Errorf("error creating CCloud Cluster API Key: %w", err)
 	}
 
@@ -119,10 +269,137 @@ func (c *ccloudAPIKeyClient) CreateApiKey(
 
 // deleteToken calls the CCloud API client to sign out and revoke the token
 func (c *ccloudAPIKeyClient) DeleteApiKey(ctx context.Context, keyId string) error {
-	ctx = c.contextWithAuth(ctx)
+	ctx, err := c.contextWithAuth(ctx)
+	if err != nil {
+		return err
+	}
 
 	req := c.client.APIKeysIamV2Api.DeleteIamV2ApiKey(ctx, keyId)
-	_, err := req.Execute()
 
-	return err
+	retried := false
+	err = c.retry.do(ctx, c.log, "delete API key", func() (*http.Response, error) {
+		resp, err := c.execute(ctx, req.Execute)
+		if err != nil && retried && resp != nil && resp.StatusCode == http.StatusNotFound {
+			// an earlier attempt deleted the key after all
+			return resp, nil
+		}
+		return resp, err
+	}, func(*http.Response, error) error {
+		retried = true
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting CCloud API Key %s: %w", keyId, err)
+	}
+
+	return nil
+}
+
+// GetApiKeyOwner looks up the owner of an existing API key
+func (c *ccloudAPIKeyClient) GetApiKeyOwner(ctx context.Context, keyId string) (owner, ownerEnv string, err error) {
+	ctx, err = c.contextWithAuth(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	req := c.client.APIKeysIamV2Api.GetIamV2ApiKey(ctx, keyId)
+
+	var v2ApiKey apikeys.IamV2ApiKey
+	err = c.retry.do(ctx, c.log, "read API key", func() (*http.Response, error) {
+		return c.execute(ctx, func() (resp *http.Response, err error) {
+			v2ApiKey, resp, err = req.Execute()
+			return resp, err
+		})
+	}, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("error reading CCloud API Key %s: %w", keyId, err)
+	}
+
+	if v2ApiKey.Spec == nil || v2ApiKey.Spec.Owner == nil || v2ApiKey.Spec.Owner.Id == "" {
+		return "", "", fmt.Errorf("CCloud API Key %s has no owner", keyId)
+	}
+
+	return v2ApiKey.Spec.Owner.Id, v2ApiKey.Spec.Owner.GetEnvironment(), nil
+}
+
+// findApiKeys lists the API keys with the given owner, resource, display
+// name and description that were created after the given time
+func (c *ccloudAPIKeyClient) findApiKeys(ctx context.Context, spec apikeys.IamV2ApiKeySpec, createdAfter time.Time) ([]string, error) {
+	var keyIds []string
+
+	pageToken := ""
+	for {
+		req := c.client.APIKeysIamV2Api.ListIamV2ApiKeys(ctx).SpecOwner(spec.GetOwner().Id)
+		if resource, ok := spec.GetResourceOk(); ok {
+			req = req.SpecResource(resource.Id)
+		}
+		if pageToken != "" {
+			req = req.PageToken(pageToken)
+		}
+
+		var list apikeys.IamV2ApiKeyList
+		_, err := c.execute(ctx, func() (resp *http.Response, err error) {
+			list, resp, err = req.Execute()
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range list.GetData() {
+			keySpec := key.GetSpec()
+			if keySpec.GetDisplayName() != spec.GetDisplayName() || keySpec.GetDescription() != spec.GetDescription() {
+				continue
+			}
+			metadata := key.GetMetadata()
+			if createdAt, ok := metadata.GetCreatedAtOk(); ok && createdAt.Before(createdAfter) {
+				continue
+			}
+			keyIds = append(keyIds, key.GetId())
+		}
+
+		listMetadata := list.GetMetadata()
+		next, err := neturl.Parse(listMetadata.GetNext())
+		if err != nil || next.Query().Get("page_token") == "" {
+			return keyIds, nil
+		}
+		pageToken = next.Query().Get("page_token")
+	}
+}
+
+// VerifyConnection makes a cheap authenticated call to check that the
+// Confluent Cloud API is reachable and that the credentials are allowed to
+// manage API keys. It is not retried, so a broken configuration is reported
+// right away.
+func (c *ccloudAPIKeyClient) VerifyConnection(ctx context.Context) error {
+	ctx, err := c.contextWithAuth(ctx)
+	if err != nil {
+		return fmt.Errorf("invalid credentials: %w", err)
+	}
+
+	req := c.client.APIKeysIamV2Api.ListIamV2ApiKeys(ctx).PageSize(1)
+	httpResp, err := c.execute(ctx, func() (resp *http.Response, err error) {
+		_, resp, err = req.Execute()
+		return resp, err
+	})
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, errRateLimited) {
+		return err
+	}
+
+	if httpResp == nil {
+		return fmt.Errorf("unable to reach the Confluent Cloud API: %w", err)
+	}
+
+	switch httpResp.StatusCode {
+	case http.StatusUnauthorized:
+		return errors.New("invalid credentials: Confluent Cloud rejected the credentials (401 Unauthorized)")
+	case http.StatusForbidden:
+		return errors.New("insufficient permissions: the credentials are valid but not allowed to manage API keys (403 Forbidden)")
+	default:
+		return fmt.Errorf("unexpected response from the Confluent Cloud API: %w", err)
+	}
 }
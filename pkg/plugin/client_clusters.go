@@ -0,0 +1,52 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+)
+
+const (
+	kafkaClustersPath          = "/cmk/v2/clusters"
+	schemaRegistryClustersPath = "/srcm/v2/clusters"
+	ksqlDBClustersPath         = "/ksqldbcm/v2/clusters"
+)
+
+// KafkaClusterExists reports whether a Kafka cluster exists in an environment
+func (c *ccloudAPIKeyClient) KafkaClusterExists(ctx context.Context, id, environment string) (bool, error) {
+	return c.exists(ctx, "read Kafka cluster", kafkaClustersPath+"/"+id, environment)
+}
+
+// SchemaRegistryClusterExists reports whether a Schema Registry cluster
+// exists in an environment
+func (c *ccloudAPIKeyClient) SchemaRegistryClusterExists(ctx context.Context, id, environment string) (bool, error) {
+	return c.exists(ctx, "read Schema Registry cluster", schemaRegistryClustersPath+"/"+id, environment)
+}
+
+// KsqlDBClusterExists reports whether a ksqlDB cluster exists in an
+// environment
+func (c *ccloudAPIKeyClient) KsqlDBClusterExists(ctx context.Context, id, environment string) (bool, error) {
+	return c.exists(ctx, "read ksqlDB cluster", ksqlDBClustersPath+"/"+id, environment)
+}
+
+// clusterEndpoint looks up the HTTP endpoint of a cluster managed through
+// one of the v2 cluster APIs
+func (c *ccloudAPIKeyClient) clusterEndpoint(ctx context.Context, kind, clustersPath, id, environment string) (string, error) {
+	var cluster struct {
+		Spec struct {
+			HttpEndpoint string `json:"http_endpoint"`
+		} `json:"spec"`
+	}
+
+	query := neturl.Values{"environment": {environment}}
+	if err := c.callJSON(ctx, "read "+kind+" cluster", http.MethodGet, clustersPath+"/"+id, query, nil, &cluster); err != nil {
+		return "", fmt.Errorf("error looking up REST endpoint of %s cluster %s: %w", kind, id, err)
+	}
+
+	if cluster.Spec.HttpEndpoint == "" {
+		return "", fmt.Errorf("%s cluster %s has no REST endpoint", kind, id)
+	}
+
+	return cluster.Spec.HttpEndpoint, nil
+}
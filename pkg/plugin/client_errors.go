@@ -0,0 +1,168 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// Kinds of failed calls to Confluent Cloud, matched with errors.Is
+var (
+	errCCloudUnauthorized  = errors.New("unauthorized")
+	errCCloudForbidden     = errors.New("forbidden")
+	errCCloudNotFound      = errors.New("not found")
+	errCCloudConflict      = errors.New("conflict")
+	errCCloudRateLimited   = errors.New("rate limited")
+	errCCloudQuotaExceeded = errors.New("quota exceeded")
+	errCCloudServerError   = errors.New("server error")
+)
+
+// ccloudAPIError is a call that Confluent Cloud answered with an error
+type ccloudAPIError struct {
+	Kind       error
+	StatusCode int
+	Code       string
+	Detail     string
+}
+
+func (e *ccloudAPIError) Error() string {
+	msg := fmt.Sprintf("Confluent Cloud API error: %s (%d)", e.Kind, e.StatusCode)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+func (e *ccloudAPIError) Unwrap() error {
+	return e.Kind
+}
+
+// ccloudErrorBody is the error document returned by the Confluent Cloud API
+type ccloudErrorBody struct {
+	Errors []struct {
+		Status string `json:"status"`
+		Code   string `json:"code"`
+		Title  string `json:"title"`
+		Detail string `json:"detail"`
+	} `json:"errors"`
+	Error *struct {
+		Code    interface{} `json:"code"`
+		Message string      `json:"message"`
+	} `json:"error"`
+	Message string `json:"message"`
+}
+
+// newAPIError classifies the error of a call that got an error response.
+// Errors of calls that got no response are returned unchanged.
+func newAPIError(resp *http.Response, err error) error {
+	if err == nil || resp == nil || resp.StatusCode < http.StatusBadRequest {
+		return err
+	}
+
+	apiErr := &ccloudAPIError{StatusCode: resp.StatusCode}
+
+	// both the errors of the SDK and of callJSON carry the response body
+	var bodyErr interface{ Body() []byte }
+	if errors.As(err, &bodyErr) {
+		apiErr.Code, apiErr.Detail = parseErrorBody(bodyErr.Body())
+	}
+	if apiErr.Detail == "" {
+		apiErr.Detail = err.Error()
+	}
+
+	apiErr.Kind = classifyError(apiErr.StatusCode, apiErr.Code, apiErr.Detail)
+
+	return apiErr
+}
+
+// parseErrorBody extracts the error code and message from an error document
+func parseErrorBody(body []byte) (code, detail string) {
+	var doc ccloudErrorBody
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return "", strings.TrimSpace(string(body))
+	}
+
+	switch {
+	case len(doc.Errors) > 0:
+		detail = doc.Errors[0].Detail
+		if detail == "" {
+			detail = doc.Errors[0].Title
+		}
+		return doc.Errors[0].Code, detail
+	case doc.Error != nil:
+		if doc.Error.Code != nil {
+			code = fmt.Sprint(doc.Error.Code)
+		}
+		return code, doc.Error.Message
+	default:
+		return "", doc.Message
+	}
+}
+
+// quotaErrorCodes are the error codes with which Confluent Cloud refuses a
+// call because a quota, such as the number of API keys of an owner, is used up
+var quotaErrorCodes = []string{"quota_exceeded", "resource_quota_exceeded"}
+
+// classifyError returns the kind of a failed call from its status, and for
+// statuses that do not tell, from its error code
+func classifyError(status int, code, detail string) error {
+	switch {
+	case status == http.StatusPaymentRequired:
+		return errCCloudQuotaExceeded
+	case status == http.StatusUnauthorized:
+		return errCCloudUnauthorized
+	case status == http.StatusNotFound:
+		return errCCloudNotFound
+	case status == http.StatusConflict:
+		return errCCloudConflict
+	case status == http.StatusTooManyRequests:
+		return errCCloudRateLimited
+	case status >= http.StatusInternalServerError:
+		return errCCloudServerError
+	case slices.Contains(quotaErrorCodes, strings.ToLower(code)):
+		return errCCloudQuotaExceeded
+	case status == http.StatusForbidden:
+		return errCCloudForbidden
+	default:
+		return fmt.Errorf("unexpected status %d", status)
+	}
+}
+
+// codedError gives errors from Confluent Cloud the HTTP status Vault
+// responds with, so clients can tell a throttled or refused request from a
+// failure of the backend. Unauthorized and server errors are failures of the
+// backend's connection rather than of the request, and are reported as a
+// bad gateway.
+func codedError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var status int
+	switch {
+	case errors.Is(err, errRateLimited), errors.Is(err, errCCloudRateLimited):
+		status = http.StatusTooManyRequests
+	case errors.Is(err, errCCloudQuotaExceeded), errors.Is(err, errCCloudForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, errCCloudNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errCCloudConflict):
+		status = http.StatusConflict
+	case errors.Is(err, errCCloudUnauthorized), errors.Is(err, errCCloudServerError):
+		status = http.StatusBadGateway
+	case errors.Is(err, errKeyNotReady):
+		status = http.StatusGatewayTimeout
+	default:
+		return err
+	}
+
+	return logical.CodedError(status, err.Error())
+}
@@ -0,0 +1,50 @@
+package plugin
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseErrorBody(t *testing.T) {
+	code, detail := parseErrorBody([]byte(`{"errors":[{"status":"403","code":"forbidden","detail":"not allowed to create API keys"}]}`))
+	assert.Equal(t, "forbidden", code)
+	assert.Equal(t, "not allowed to create API keys", detail)
+
+	code, detail = parseErrorBody([]byte(`{"error":{"code":409,"message":"already exists"}}`))
+	assert.Equal(t, "409", code)
+	assert.Equal(t, "already exists", detail)
+
+	code, detail = parseErrorBody([]byte("upstream connect error"))
+	assert.Empty(t, code)
+	assert.Equal(t, "upstream connect error", detail)
+}
+
+func TestNewAPIErrorClassifiesResponses(t *testing.T) {
+	failure := errors.New("request failed")
+
+	for status, kind := range map[int]error{
+		http.StatusUnauthorized:       errCCloudUnauthorized,
+		http.StatusForbidden:          errCCloudForbidden,
+		http.StatusNotFound:           errCCloudNotFound,
+		http.StatusConflict:           errCCloudConflict,
+		http.StatusTooManyRequests:    errCCloudRateLimited,
+		http.StatusPaymentRequired:    errCCloudQuotaExceeded,
+		http.StatusServiceUnavailable: errCCloudServerError,
+	} {
+		err := newAPIError(&http.Response{StatusCode: status}, failure)
+		assert.ErrorIs(t, err, kind, "status %d", status)
+	}
+
+	// quotas are told apart by their error code, not by their message
+	assert.ErrorIs(t, classifyError(http.StatusBadRequest, "quota_exceeded", "API key quota exceeded for service account"), errCCloudQuotaExceeded)
+	assert.ErrorIs(t, classifyError(http.StatusForbidden, "QUOTA_EXCEEDED", "API key quota exceeded for service account"), errCCloudQuotaExceeded)
+	assert.ErrorIs(t, classifyError(http.StatusTooManyRequests, "", "request quota exceeded, slow down"), errCCloudRateLimited)
+	assert.NotErrorIs(t, classifyError(http.StatusBadRequest, "invalid_field", "maximum number of characters is 64"), errCCloudQuotaExceeded)
+	assert.NotErrorIs(t, classifyError(http.StatusBadRequest, "", "API key quota exceeded for service account"), errCCloudQuotaExceeded)
+
+	// calls without a response are not classified
+	assert.Equal(t, failure, newAPIError(nil, failure))
+}
@@ -0,0 +1,75 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	serviceAccountsPath = "/iam/v2/service-accounts"
+	usersPath           = "/iam/v2/users"
+)
+
+// ServiceAccountExists reports whether a service account exists
+func (c *ccloudAPIKeyClient) ServiceAccountExists(ctx context.Context, id string) (bool, error) {
+	return c.exists(ctx, "read service account", serviceAccountsPath+"/"+id, "")
+}
+
+// CreateServiceAccount creates a service account and returns its ID. It is
+// not retried, because a failed call may still have created the account.
+func (c *ccloudAPIKeyClient) CreateServiceAccount(ctx context.Context, displayName, description string) (string, error) {
+	var serviceAccount struct {
+		Id string `json:"id"`
+	}
+
+	err := c.callJSON(ctx, "create service account", http.MethodPost, serviceAccountsPath, nil, map[string]string{
+		"display_name": displayName,
+		"description":  description,
+	}, &serviceAccount)
+	if err != nil {
+		return "", fmt.Errorf("error creating service account %s: %w", displayName, err)
+	}
+
+	if serviceAccount.Id == "" {
+		return "", fmt.Errorf("service account %s was created without an ID", displayName)
+	}
+
+	return serviceAccount.Id, nil
+}
+
+// DeleteServiceAccount deletes a service account. A service account that
+// does not exist is considered deleted, so deletion can be repeated.
+func (c *ccloudAPIKeyClient) DeleteServiceAccount(ctx context.Context, id string) error {
+	err := c.callJSON(ctx, "delete service account", http.MethodDelete, serviceAccountsPath+"/"+id, nil, nil, nil)
+	if err != nil && !errors.Is(err, errCCloudNotFound) {
+		return fmt.Errorf("error deleting service account %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// UserExists reports whether a user exists
+func (c *ccloudAPIKeyClient) UserExists(ctx context.Context, id string) (bool, error) {
+	return c.exists(ctx, "read user", usersPath+"/"+id, "")
+}
+
+// exists reads an object, optionally scoped to an environment, and reports
+// whether it was found
+func (c *ccloudAPIKeyClient) exists(ctx context.Context, operation, path, environment string) (bool, error) {
+	var query map[string][]string
+	if environment != "" {
+		query = map[string][]string{"environment": {environment}}
+	}
+
+	err := c.callJSON(ctx, operation, http.MethodGet, path, query, nil, nil)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, errCCloudNotFound):
+		return false, nil
+	default:
+		return false, err
+	}
+}
@@ -0,0 +1,72 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+
+	apikeys "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
+)
+
+// kafkaACLsPath returns the path of the ACLs of a cluster in Kafka REST v3
+func kafkaACLsPath(clusterId string) string {
+	return "/kafka/v3/clusters/" + neturl.PathEscape(clusterId) + "/acls"
+}
+
+// KafkaRESTEndpoint looks up the REST endpoint of a Kafka cluster
+func (c *ccloudAPIKeyClient) KafkaRESTEndpoint(ctx context.Context, id, environment string) (string, error) {
+	return c.clusterEndpoint(ctx, "Kafka", kafkaClustersPath, id, environment)
+}
+
+// kafkaRESTAuthorizer returns a function that authorizes requests to Kafka
+// REST with a Kafka API key of the cluster. Kafka REST does not accept the
+// Cloud API keys or tokens of the client.
+func kafkaRESTAuthorizer(auth apikeys.BasicAuth) func(context.Context, *http.Request) error {
+	return func(_ context.Context, req *http.Request) error {
+		req.SetBasicAuth(auth.UserName, auth.Password)
+		return nil
+	}
+}
+
+// CreateKafkaACL creates an ACL for a principal through the REST endpoint of
+// a Kafka cluster, authenticated with a Kafka API key of the cluster.
+// Creating an ACL that exists has no effect.
+func (c *ccloudAPIKeyClient) CreateKafkaACL(ctx context.Context, endpoint, clusterId string, auth apikeys.BasicAuth, principal string, acl kafkaACL) error {
+	err := c.callJSONAt(ctx, "create Kafka ACL", http.MethodPost, endpoint, kafkaACLsPath(clusterId), nil, kafkaRESTAuthorizer(auth), map[string]string{
+		"resource_type": acl.ResourceType,
+		"resource_name": acl.ResourceName,
+		"pattern_type":  acl.PatternType,
+		"principal":     principal,
+		"host":          acl.Host,
+		"operation":     acl.Operation,
+		"permission":    acl.Permission,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("error creating %s ACL on %s %s for %s: %w", acl.Operation, acl.ResourceType, acl.ResourceName, principal, err)
+	}
+
+	return nil
+}
+
+// DeleteKafkaACL deletes exactly the ACL of a principal. The filter names
+// every field, so no other ACL matches it. Deleting an ACL that does not
+// exist has no effect.
+func (c *ccloudAPIKeyClient) DeleteKafkaACL(ctx context.Context, endpoint, clusterId string, auth apikeys.BasicAuth, principal string, acl kafkaACL) error {
+	query := neturl.Values{
+		"resource_type": {acl.ResourceType},
+		"resource_name": {acl.ResourceName},
+		"pattern_type":  {acl.PatternType},
+		"principal":     {principal},
+		"host":          {acl.Host},
+		"operation":     {acl.Operation},
+		"permission":    {acl.Permission},
+	}
+
+	err := c.callJSONAt(ctx, "delete Kafka ACL", http.MethodDelete, endpoint, kafkaACLsPath(clusterId), query, kafkaRESTAuthorizer(auth), nil, nil)
+	if err != nil {
+		return fmt.Errorf("error deleting %s ACL on %s %s for %s: %w", acl.Operation, acl.ResourceType, acl.ResourceName, principal, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/helper/pluginidentityutil"
+	"github.com/hashicorp/vault/sdk/helper/pluginutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	stsTokenPath = "/sts/v1/oauth2/token"
+
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	jwtTokenType           = "urn:ietf:params:oauth:token-type:jwt"
+	accessTokenType        = "urn:ietf:params:oauth:token-type:access_token"
+
+	// tokenExpiryDelta is how long before its expiry a cached token is
+	// refreshed, so it does not expire while a request is in flight
+	tokenExpiryDelta = 1 * time.Minute
+)
+
+// subjectTokenFunc returns a token issued by an external identity provider
+// that can be exchanged for a Confluent Cloud access token
+type subjectTokenFunc func(ctx context.Context) (string, error)
+
+// ccloudTokenSource exchanges tokens from an external identity provider for
+// Confluent Cloud access tokens at the Confluent Cloud Security Token Service
+// and caches them until shortly before they expire.
+type ccloudTokenSource struct {
+	httpClient     *http.Client
+	stsURL         string
+	identityPoolId string
+	subjectToken   subjectTokenFunc
+
+	lock   sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func newTokenSource(httpClient *http.Client, stsURL, identityPoolId string, subjectToken subjectTokenFunc) *ccloudTokenSource {
+	return &ccloudTokenSource{
+		httpClient:     httpClient,
+		stsURL:         stsURL,
+		identityPoolId: identityPoolId,
+		subjectToken:   subjectToken,
+	}
+}
+
+// clientCredentialsSubjectToken fetches subject tokens with the OAuth client
+// credentials grant
+func clientCredentialsSubjectToken(httpClient *http.Client, config *ccloudConfig) subjectTokenFunc {
+	credentials := &clientcredentials.Config{
+		ClientID:     config.OAuthClientId,
+		ClientSecret: config.OAuthClientSecret,
+		TokenURL:     config.OAuthTokenURL,
+		Scopes:       config.OAuthScopes,
+	}
+
+	return func(ctx context.Context) (string, error) {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, httpClient)
+
+		token, err := credentials.Token(ctx)
+		if err != nil {
+			return "", fmt.Errorf("error fetching token from %s: %w", config.OAuthTokenURL, err)
+		}
+
+		return token.AccessToken, nil
+	}
+}
+
+// pluginIdentitySubjectToken requests plugin identity tokens signed by Vault
+func pluginIdentitySubjectToken(system logical.SystemView, config *ccloudConfig) subjectTokenFunc {
+	return func(ctx context.Context) (string, error) {
+		resp, err := system.GenerateIdentityToken(ctx, &pluginutil.IdentityTokenRequest{
+			Audience: config.IdentityTokenAudience,
+			TTL:      config.IdentityTokenTTL,
+		})
+		if err != nil {
+			if errors.Is(err, pluginidentityutil.ErrPluginWorkloadIdentityUnsupported) {
+				return "", err
+			}
+			return "", fmt.Errorf("error generating plugin identity token: %w", err)
+		}
+
+		return resp.Token.Token(), nil
+	}
+}
+
+// Token returns a valid Confluent Cloud access token, refreshing it if
+// needed.
+func (ts *ccloudTokenSource) Token(ctx context.Context) (string, error) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	if ts.token != "" && time.Now().Add(tokenExpiryDelta).Before(ts.expiry) {
+		return ts.token, nil
+	}
+
+	subjectToken, err := ts.subjectToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	token, expiry, err := ts.exchange(ctx, subjectToken)
+	if err != nil {
+		return "", err
+	}
+
+	ts.token = token
+	ts.expiry = expiry
+
+	return token, nil
+}
+
+// exchange trades a subject token for a Confluent Cloud access token
+func (ts *ccloudTokenSource) exchange(ctx context.Context, subjectToken string) (string, time.Time, error) {
+	form := neturl.Values{
+		"grant_type":           {tokenExchangeGrantType},
+		"subject_token":        {subjectToken},
+		"subject_token_type":   {jwtTokenType},
+		"requested_token_type": {accessTokenType},
+		"identity_pool_id":     {ts.identityPoolId},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.stsURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := ts.httpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error exchanging token at %s: %w", ts.stsURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error reading token exchange response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("error exchanging token at %s: %s. Ccloud response: %s", ts.stsURL, resp.Status, string(body))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", time.Time{}, fmt.Errorf("error decoding token exchange response: %w", err)
+	}
+
+	if token.AccessToken == "" {
+		return "", time.Time{}, errors.New("token exchange response did not contain an access token")
+	}
+
+	return token.AccessToken, time.Now().Add(time.Duration(token.ExpiresIn) * time.Second), nil
+}
@@ -0,0 +1,231 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/helper/pluginutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testOAuthClientId     = "vault-client"
+	testOAuthClientSecret = "vault-client-secret"
+)
+
+// newTestIdentityProvider serves an OAuth token endpoint that issues
+// fakeSubjectToken for the client credentials grant
+func newTestIdentityProvider(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientId, clientSecret, ok := r.BasicAuth()
+		if !ok {
+			_ = r.ParseForm()
+			clientId, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+		}
+		if clientId != testOAuthClientId || clientSecret != testOAuthClientSecret {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fakeSubjectToken,
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}))
+	t.Cleanup(idp.Close)
+
+	return idp
+}
+
+func TestOAuthClientCreatesAndCachesAccessToken(t *testing.T) {
+	fake := newFakeCCloud(t)
+	idp := newTestIdentityProvider(t)
+
+	client, err := newClient(&ccloudConfig{
+		URL:               fake.URL,
+		OAuthTokenURL:     idp.URL,
+		OAuthClientId:     testOAuthClientId,
+		OAuthClientSecret: testOAuthClientSecret,
+		IdentityPoolId:    fakeIdentityPoolId,
+	}, hclog.NewNullLogger())
+	require.NoError(t, err)
+
+	keyId, secret, err := client.CreateApiKey(context.Background(), owner, "", resource, "", "", "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, secret)
+	require.NoError(t, client.DeleteApiKey(context.Background(), keyId))
+	assert.Equal(t, 1, fake.tokenExchanges())
+
+	// an expiring token is refreshed
+	client.tokenSource.expiry = time.Now().Add(tokenExpiryDelta / 2)
+
+	_, _, err = client.CreateApiKey(context.Background(), owner, "", resource, "", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, fake.tokenExchanges())
+}
+
+func TestOAuthClientReturnsErrorWhenClientCredentialsAreRejected(t *testing.T) {
+	fake := newFakeCCloud(t)
+	idp := newTestIdentityProvider(t)
+
+	client, err := newClient(&ccloudConfig{
+		URL:               fake.URL,
+		OAuthTokenURL:     idp.URL,
+		OAuthClientId:     testOAuthClientId,
+		OAuthClientSecret: "wrong",
+		IdentityPoolId:    fakeIdentityPoolId,
+	}, hclog.NewNullLogger())
+	require.NoError(t, err)
+
+	_, _, err = client.CreateApiKey(context.Background(), owner, "", resource, "", "", "")
+	assert.ErrorContains(t, err, "error getting CCloud access token")
+	assert.Equal(t, 0, fake.tokenExchanges())
+}
+
+func TestOAuthClientReturnsErrorWhenIdentityPoolIsNotDefined(t *testing.T) {
+	_, err := newClient(&ccloudConfig{
+		URL:               "http://localhost:19090",
+		OAuthTokenURL:     "http://localhost:19091",
+		OAuthClientId:     testOAuthClientId,
+		OAuthClientSecret: testOAuthClientSecret,
+	}, hclog.NewNullLogger())
+
+	assert.EqualError(t, err, "identity pool ID not defined")
+}
+
+func TestConfigWriteWithOAuth(t *testing.T) {
+	fake := newFakeCCloud(t)
+	idp := newTestIdentityProvider(t)
+	b, s := getTestBackend(t)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      configStoragePath,
+		Data: map[string]interface{}{
+			"url":                 fake.URL,
+			"oauth_token_url":     idp.URL,
+			"oauth_client_id":     testOAuthClientId,
+			"oauth_client_secret": testOAuthClientSecret,
+			"identity_pool_id":    fakeIdentityPoolId,
+		},
+		Storage: s,
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      configStoragePath,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, idp.URL, resp.Data["oauth_token_url"])
+	assert.NotContains(t, resp.Data, "oauth_client_secret")
+
+	client, err := b.getClient(context.Background(), s, defaultConnectionName)
+	require.NoError(t, err)
+	require.NotNil(t, client.tokenSource)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      configStoragePath,
+		Data:      map[string]interface{}{"identity_pool_id": ""},
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.True(t, resp.IsError())
+}
+
+// identityTokenSystemView issues fakeSubjectToken as plugin identity token
+type identityTokenSystemView struct {
+	logical.StaticSystemView
+
+	requests []*pluginutil.IdentityTokenRequest
+}
+
+func (v *identityTokenSystemView) GenerateIdentityToken(_ context.Context, req *pluginutil.IdentityTokenRequest) (*pluginutil.IdentityTokenResponse, error) {
+	v.requests = append(v.requests, req)
+	return &pluginutil.IdentityTokenResponse{
+		Token: pluginutil.IdentityToken(fakeSubjectToken),
+		TTL:   req.TTL,
+	}, nil
+}
+
+func TestWorkloadIdentityExchangesPluginIdentityToken(t *testing.T) {
+	fake := newFakeCCloud(t)
+	system := &identityTokenSystemView{StaticSystemView: *logical.TestSystemView()}
+
+	config := logical.TestBackendConfig()
+	config.StorageView = new(logical.InmemStorage)
+	config.Logger = hclog.NewNullLogger()
+	config.System = system
+	backend, err := Factory(context.Background(), config)
+	require.NoError(t, err)
+	b, s := backend.(*ccloudBackend), config.StorageView
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      configStoragePath,
+		Data: map[string]interface{}{
+			"url":                     fake.URL,
+			"identity_pool_id":        fakeIdentityPoolId,
+			"identity_token_audience": "confluent",
+			"identity_token_ttl":      "10m",
+		},
+		Storage: s,
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      configStoragePath,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "confluent", resp.Data["identity_token_audience"])
+	assert.Equal(t, int64(600), resp.Data["identity_token_ttl"])
+
+	client, err := b.getClient(context.Background(), s, defaultConnectionName)
+	require.NoError(t, err)
+
+	keyId, _, err := client.CreateApiKey(context.Background(), owner, "", resource, "", "", "")
+	require.NoError(t, err)
+	assert.True(t, fake.hasKey(keyId))
+	assert.Equal(t, 1, fake.tokenExchanges())
+
+	require.Len(t, system.requests, 1)
+	assert.Equal(t, "confluent", system.requests[0].Audience)
+	assert.Equal(t, 10*time.Minute, system.requests[0].TTL)
+}
+
+func TestWorkloadIdentityIsUnsupportedWithoutVaultEnterprise(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      configStoragePath,
+		Data: map[string]interface{}{
+			"url":                     fake.URL,
+			"identity_pool_id":        fakeIdentityPoolId,
+			"identity_token_audience": "confluent",
+		},
+		Storage: s,
+	})
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "error generating plugin identity token")
+	assert.Equal(t, 0, fake.tokenExchanges())
+}
@@ -0,0 +1,81 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/time/rate"
+)
+
+// defaultRateLimitWait is how long a call waits for the local rate limiter
+// before it fails
+const defaultRateLimitWait = 30 * time.Second
+
+// errRateLimited is returned when a call could not be made within the wait
+// time of the local rate limiter. It is never retried.
+var errRateLimited = errors.New("rate limited locally")
+
+// ccloudLimiter limits the rate and the concurrency of the calls a client
+// makes to Confluent Cloud, so a burst of credential requests is spread out
+// instead of getting the whole organization throttled.
+type ccloudLimiter struct {
+	rate     *rate.Limiter
+	inFlight *semaphore.Weighted
+	wait     time.Duration
+}
+
+func newLimiter(config *ccloudConfig) *ccloudLimiter {
+	limiter := &ccloudLimiter{
+		wait: config.RateLimitWait,
+	}
+
+	if config.RateLimit > 0 {
+		burst := config.RateLimitBurst
+		if burst <= 0 {
+			burst = int(math.Ceil(config.RateLimit))
+		}
+		limiter.rate = rate.NewLimiter(rate.Limit(config.RateLimit), burst)
+	}
+
+	if config.MaxInFlight > 0 {
+		limiter.inFlight = semaphore.NewWeighted(int64(config.MaxInFlight))
+	}
+
+	return limiter
+}
+
+// acquire waits until a call may be made. The returned function must be
+// called once the call has completed.
+func (l *ccloudLimiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil || (l.rate == nil && l.inFlight == nil) {
+		return func() {}, nil
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, l.wait)
+	defer cancel()
+
+	if l.rate != nil {
+		if err := l.rate.Wait(waitCtx); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("%w: no capacity for a call to Confluent Cloud within %s", errRateLimited, l.wait)
+		}
+	}
+
+	if l.inFlight != nil {
+		if err := l.inFlight.Acquire(waitCtx, 1); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("%w: too many concurrent calls to Confluent Cloud for %s", errRateLimited, l.wait)
+		}
+		return func() { l.inFlight.Release(1) }, nil
+	}
+
+	return func() {}, nil
+}
@@ -0,0 +1,101 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientFailsWhenRateLimitedLocally(t *testing.T) {
+	fake := newFakeCCloud(t)
+
+	client, err := newClient(&ccloudConfig{
+		ApiKeyId:       fakeRootKeyId,
+		ApiKeySecret:   fakeRootKeySecret,
+		URL:            fake.URL,
+		MaxRetries:     3,
+		RateLimit:      0.1,
+		RateLimitBurst: 1,
+		RateLimitWait:  50 * time.Millisecond,
+	}, nil)
+	require.NoError(t, err)
+
+	_, _, err = client.GetApiKeyOwner(context.Background(), fakeRootKeyId)
+	require.NoError(t, err)
+
+	_, _, err = client.GetApiKeyOwner(context.Background(), fakeRootKeyId)
+	require.ErrorIs(t, err, errRateLimited)
+	assert.Contains(t, err.Error(), "rate limited locally")
+	assert.Equal(t, 1, fake.requestCount(http.MethodGet), "calls that are rate limited locally must not reach Confluent Cloud")
+}
+
+func TestClientCapsConcurrentCalls(t *testing.T) {
+	var current, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&current, 1)
+		defer atomic.AddInt32(&current, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data": []}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := newClient(&ccloudConfig{
+		ApiKeyId:      fakeRootKeyId,
+		ApiKeySecret:  fakeRootKeySecret,
+		URL:           server.URL,
+		MaxInFlight:   2,
+		RateLimitWait: 5 * time.Second,
+	}, nil)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 6)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = client.VerifyConnection(context.Background())
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&peak))
+}
+
+func TestConfigRejectsNegativeRateLimits(t *testing.T) {
+	logicalBackend, logicalStorage := getTestBackend(t)
+
+	resp, err := logicalBackend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      configStoragePath,
+		Data: map[string]interface{}{
+			"ccloud_api_key_id":     fakeRootKeyId,
+			"ccloud_api_key_secret": fakeRootKeySecret,
+			"url":                   url,
+			"verify_connection":     false,
+			"max_in_flight":         -1,
+		},
+		Storage: logicalStorage,
+	})
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+}
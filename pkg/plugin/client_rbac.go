@@ -0,0 +1,49 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const roleBindingsPath = "/iam/v2/role-bindings"
+
+// principalPrefix is the prefix of the principal of a user or service
+// account in role bindings
+const principalPrefix = "User:"
+
+// CreateRoleBinding binds a role to the owner of a key on the resources
+// matching a CRN pattern, and returns the ID of the binding. It is not
+// retried, because a failed call may still have created the binding.
+func (c *ccloudAPIKeyClient) CreateRoleBinding(ctx context.Context, owner string, binding roleBinding) (string, error) {
+	var created struct {
+		Id string `json:"id"`
+	}
+
+	err := c.callJSON(ctx, "create role binding", http.MethodPost, roleBindingsPath, nil, map[string]string{
+		"principal":   principalPrefix + owner,
+		"role_name":   binding.RoleName,
+		"crn_pattern": binding.CRNPattern,
+	}, &created)
+	if err != nil {
+		return "", fmt.Errorf("error binding role %s to %s: %w", binding.RoleName, owner, err)
+	}
+
+	if created.Id == "" {
+		return "", fmt.Errorf("role binding of %s to %s was created without an ID", binding.RoleName, owner)
+	}
+
+	return created.Id, nil
+}
+
+// DeleteRoleBinding deletes a role binding. A binding that does not exist is
+// considered deleted, so deletion can be repeated.
+func (c *ccloudAPIKeyClient) DeleteRoleBinding(ctx context.Context, id string) error {
+	err := c.callJSON(ctx, "delete role binding", http.MethodDelete, roleBindingsPath+"/"+id, nil, nil, nil)
+	if err != nil && !errors.Is(err, errCCloudNotFound) {
+		return fmt.Errorf("error deleting role binding %s: %w", id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+)
+
+// readyProbe is an authenticated request that tells whether a new key is
+// accepted by the resource it is scoped to
+type readyProbe struct {
+	baseURL string
+	path    string
+}
+
+func (p readyProbe) String() string {
+	return strings.TrimSuffix(p.baseURL, "/") + p.path
+}
+
+// ReadyProbe returns the request that checks whether a key of a kind is
+// accepted: reading the key itself for Cloud keys, the cluster metadata for
+// Kafka keys, the subjects for Schema Registry keys and the server info for
+// ksqlDB keys.
+func (c *ccloudAPIKeyClient) ReadyProbe(ctx context.Context, kind, keyId, resource, resourceEnv string) (readyProbe, error) {
+	switch kind {
+	case keyKindCloud:
+		return readyProbe{baseURL: c.baseURL, path: "/iam/v2/api-keys/" + neturl.PathEscape(keyId)}, nil
+	case keyKindKafka:
+		endpoint, err := c.KafkaRESTEndpoint(ctx, resource, resourceEnv)
+		if err != nil {
+			return readyProbe{}, err
+		}
+		return readyProbe{baseURL: endpoint, path: "/kafka/v3/clusters/" + neturl.PathEscape(resource)}, nil
+	case keyKindSchemaRegistry:
+		endpoint, err := c.clusterEndpoint(ctx, "Schema Registry", schemaRegistryClustersPath, resource, resourceEnv)
+		if err != nil {
+			return readyProbe{}, err
+		}
+		return readyProbe{baseURL: endpoint, path: "/subjects"}, nil
+	case keyKindKsqlDB:
+		endpoint, err := c.clusterEndpoint(ctx, "ksqlDB", ksqlDBClustersPath, resource, resourceEnv)
+		if err != nil {
+			return readyProbe{}, err
+		}
+		return readyProbe{baseURL: endpoint, path: "/info"}, nil
+	default:
+		return readyProbe{}, fmt.Errorf("cannot check whether %s keys are ready", kind)
+	}
+}
+
+// KeyAccepted makes the probe request once with a key and reports whether
+// the key authenticated. Any response but 401 Unauthorized means it did,
+// even if the key is not allowed to read the probed resource.
+func (c *ccloudAPIKeyClient) KeyAccepted(ctx context.Context, probe readyProbe, keyId, secret string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probe.String(), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(keyId, secret)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	return resp.StatusCode != http.StatusUnauthorized, nil
+}
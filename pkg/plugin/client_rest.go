@@ -0,0 +1,118 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+)
+
+// responseError is an error response of a call made with callJSON
+type responseError struct {
+	status string
+	body   []byte
+}
+
+func (e *responseError) Error() string {
+	return e.status
+}
+
+// Body returns the body of the error response
+func (e *responseError) Body() []byte {
+	return e.body
+}
+
+// authorize adds the client's credentials to a request
+func (c *ccloudAPIKeyClient) authorize(ctx context.Context, req *http.Request) error {
+	if c.tokenSource != nil {
+		token, err := c.tokenSource.Token(ctx)
+		if err != nil {
+			return fmt.Errorf("error getting CCloud access token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+
+	if c.authBasic != nil {
+		req.SetBasicAuth(c.authBasic.UserName, c.authBasic.Password)
+	}
+
+	return nil
+}
+
+// callJSON calls a Confluent Cloud REST API that the API keys SDK does not
+// cover. The request body is encoded from in and the response decoded into
+// out, if they are not nil. Only idempotent calls are retried.
+func (c *ccloudAPIKeyClient) callJSON(ctx context.Context, operation, method, path string, query neturl.Values, in, out interface{}) error {
+	return c.callJSONAt(ctx, operation, method, c.baseURL, path, query, c.authorize, in, out)
+}
+
+// callJSONAt is callJSON for APIs served from another base URL with other
+// credentials, such as the REST endpoint of a Kafka cluster
+func (c *ccloudAPIKeyClient) callJSONAt(ctx context.Context, operation, method, baseURL, path string, query neturl.Values, authorize func(context.Context, *http.Request) error, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	target := strings.TrimSuffix(baseURL, "/") + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	policy := c.retry
+	if method == http.MethodPost || method == http.MethodPatch {
+		policy.maxRetries = 0
+	}
+
+	err := policy.do(ctx, c.log, operation, func() (*http.Response, error) {
+		return c.execute(ctx, func() (*http.Response, error) {
+			req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Accept", "application/json")
+			if in != nil {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if err := authorize(ctx, req); err != nil {
+				return nil, err
+			}
+
+			resp, err := c.httpClient.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+
+			respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+			if err != nil {
+				return resp, err
+			}
+
+			if resp.StatusCode >= http.StatusBadRequest {
+				return resp, &responseError{status: resp.Status, body: respBody}
+			}
+
+			if out != nil && len(respBody) > 0 {
+				if err := json.Unmarshal(respBody, out); err != nil {
+					return resp, fmt.Errorf("error decoding response: %w", err)
+				}
+			}
+
+			return resp, nil
+		})
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("error calling %s %s: %w", method, path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+const (
+	defaultMaxRetries  = 3
+	defaultRetryBudget = 1 * time.Minute
+
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 10 * time.Second
+
+	// createdAtSkew allows for clock skew between Vault and Confluent Cloud
+	// when looking for keys created by a failed call
+	createdAtSkew = 5 * time.Minute
+)
+
+// retryPolicy decides whether and when a failed call to Confluent Cloud is
+// retried. Calls are retried with jittered exponential backoff, or after the
+// delay requested by a Retry-After header, until either the maximum number of
+// retries or the retry budget is used up.
+type retryPolicy struct {
+	maxRetries int
+	budget     time.Duration
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+func newRetryPolicy(config *ccloudConfig) retryPolicy {
+	return retryPolicy{
+		maxRetries: config.MaxRetries,
+		budget:     config.RetryBudget,
+		baseDelay:  retryBaseDelay,
+		maxDelay:   retryMaxDelay,
+	}
+}
+
+// retryCheckFunc is called before a failed call is retried. Returning an
+// error stops retrying and fails the call with that error.
+type retryCheckFunc func(resp *http.Response, err error) error
+
+// do runs call until it succeeds, fails with an error that is not worth
+// retrying, or the policy gives up. It returns the error of the last attempt.
+func (p retryPolicy) do(ctx context.Context, log hclog.Logger, operation string, call func() (*http.Response, error), check retryCheckFunc) error {
+	var deadline time.Time
+	if p.budget > 0 {
+		deadline = time.Now().Add(p.budget)
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := call()
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= p.maxRetries || ctx.Err() != nil || errors.Is(err, errRateLimited) || !isRetryable(resp) {
+			return err
+		}
+
+		delay := p.delay(attempt, resp)
+		if !deadline.IsZero() && time.Now().Add(delay).After(deadline) {
+			return err
+		}
+
+		if check != nil {
+			if err := check(resp, err); err != nil {
+				return err
+			}
+		}
+
+		log.Debug("retrying CCloud API call", "operation", operation, "attempt", attempt+1, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// delay returns how long to wait before the next attempt. A Retry-After
+// header takes precedence over the exponential backoff.
+func (p retryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	if retryAfter, ok := parseRetryAfter(resp); ok {
+		return retryAfter
+	}
+
+	backoff := p.maxDelay
+	if attempt < 32 {
+		if d := p.baseDelay << attempt; d > 0 && d < p.maxDelay {
+			backoff = d
+		}
+	}
+
+	// full jitter on the upper half spreads out retries of concurrent calls
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// parseRetryAfter reads the Retry-After header, given either in seconds or as
+// an HTTP date
+func parseRetryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+// isRetryable reports whether a call that failed with the given response may
+// succeed when retried. Calls that did not get a response at all are retried
+// as well.
+func isRetryable(resp *http.Response) bool {
+	if resp == nil {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// isAmbiguous reports whether a failed call may still have taken effect in
+// Confluent Cloud, because no response was received or the server failed
+// while handling it
+func isAmbiguous(resp *http.Response) bool {
+	return resp == nil || resp.StatusCode >= http.StatusInternalServerError
+}
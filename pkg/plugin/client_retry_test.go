@@ -0,0 +1,204 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRetryTestClient creates a client for the fake that retries without
+// noticeable delays
+func newRetryTestClient(t *testing.T, fake *fakeCCloud, maxRetries int) *ccloudAPIKeyClient {
+	t.Helper()
+
+	client, err := newClient(&ccloudConfig{
+		ApiKeyId:     fakeRootKeyId,
+		ApiKeySecret: fakeRootKeySecret,
+		URL:          fake.URL,
+		MaxRetries:   maxRetries,
+		RetryBudget:  time.Minute,
+	}, nil)
+	require.NoError(t, err)
+
+	client.retry.baseDelay = time.Millisecond
+	client.retry.maxDelay = 10 * time.Millisecond
+
+	return client
+}
+
+func TestCreateApiKeyRetriesTransientFailures(t *testing.T) {
+	fake := newFakeCCloud(t)
+	client := newRetryTestClient(t, fake, 3)
+
+	fake.failNext(http.MethodPost,
+		fakeFailure{status: http.StatusTooManyRequests},
+		fakeFailure{status: http.StatusServiceUnavailable},
+	)
+
+	keyId, keySecret, err := client.CreateApiKey(context.Background(), "sa-1", "", "", "", "name", "description")
+	require.NoError(t, err)
+	assert.NotEmpty(t, keySecret)
+	assert.True(t, fake.hasKey(keyId))
+	assert.Equal(t, 3, fake.requestCount(http.MethodPost))
+	assert.Equal(t, 2, fake.keyCount())
+}
+
+func TestCreateApiKeyIsNotRetriedOnClientErrors(t *testing.T) {
+	fake := newFakeCCloud(t)
+	client := newRetryTestClient(t, fake, 3)
+
+	fake.failNext(http.MethodPost, fakeFailure{status: http.StatusBadRequest})
+
+	_, _, err := client.CreateApiKey(context.Background(), "sa-1", "", "", "", "name", "description")
+	require.Error(t, err)
+	assert.Equal(t, 1, fake.requestCount(http.MethodPost))
+}
+
+func TestCreateApiKeyIsRetriedWhenNoKeyWasCreated(t *testing.T) {
+	fake := newFakeCCloud(t)
+	client := newRetryTestClient(t, fake, 3)
+
+	fake.failNext(http.MethodPost, fakeFailure{status: 0})
+
+	_, _, err := client.CreateApiKey(context.Background(), "sa-1", "", "", "", "name", "description")
+	require.NoError(t, err)
+	assert.Equal(t, 2, fake.requestCount(http.MethodPost))
+	assert.Equal(t, 1, fake.requestCount(http.MethodGet), "creation must only be retried after checking for created keys")
+	assert.Equal(t, 2, fake.keyCount())
+}
+
+func TestCreateApiKeyIsNotRetriedWhenKeyMayHaveBeenCreated(t *testing.T) {
+	fake := newFakeCCloud(t)
+	client := newRetryTestClient(t, fake, 3)
+
+	fake.failNext(http.MethodPost, fakeFailure{status: http.StatusBadGateway, afterHandling: true})
+
+	_, _, err := client.CreateApiKey(context.Background(), "sa-1", "", "", "", "name", "description")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "KEY1 may have been created")
+	assert.Equal(t, 1, fake.requestCount(http.MethodPost))
+	assert.Equal(t, 2, fake.keyCount())
+}
+
+func TestCreateApiKeyIsNotRetriedWhenCreatedKeysCannotBeChecked(t *testing.T) {
+	fake := newFakeCCloud(t)
+	client := newRetryTestClient(t, fake, 3)
+
+	fake.failNext(http.MethodPost, fakeFailure{status: http.StatusInternalServerError})
+	fake.failNext(http.MethodGet, fakeFailure{status: http.StatusInternalServerError})
+
+	_, _, err := client.CreateApiKey(context.Background(), "sa-1", "", "", "", "name", "description")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to check whether the API key was created")
+	assert.Equal(t, 1, fake.requestCount(http.MethodPost))
+}
+
+func TestDeleteApiKeyRetriesAndToleratesEarlierDeletion(t *testing.T) {
+	fake := newFakeCCloud(t)
+	client := newRetryTestClient(t, fake, 3)
+
+	keyId, _, err := client.CreateApiKey(context.Background(), "sa-1", "", "", "", "", "")
+	require.NoError(t, err)
+
+	fake.failNext(http.MethodDelete, fakeFailure{status: http.StatusGatewayTimeout, afterHandling: true})
+
+	require.NoError(t, client.DeleteApiKey(context.Background(), keyId))
+	assert.False(t, fake.hasKey(keyId))
+	assert.Equal(t, 2, fake.requestCount(http.MethodDelete))
+
+	// a key that never existed is still an error
+	require.Error(t, client.DeleteApiKey(context.Background(), "MISSING"))
+}
+
+func TestRetryStopsAtMaxRetries(t *testing.T) {
+	fake := newFakeCCloud(t)
+	client := newRetryTestClient(t, fake, 2)
+
+	for i := 0; i < 4; i++ {
+		fake.failNext(http.MethodGet, fakeFailure{status: http.StatusServiceUnavailable})
+	}
+
+	_, _, err := client.GetApiKeyOwner(context.Background(), fakeRootKeyId)
+	require.Error(t, err)
+	assert.Equal(t, 3, fake.requestCount(http.MethodGet))
+}
+
+func TestRetryStopsWhenRetryAfterExceedsBudget(t *testing.T) {
+	fake := newFakeCCloud(t)
+	client := newRetryTestClient(t, fake, 3)
+
+	fake.failNext(http.MethodGet, fakeFailure{status: http.StatusTooManyRequests, retryAfter: "120"})
+
+	_, _, err := client.GetApiKeyOwner(context.Background(), fakeRootKeyId)
+	require.Error(t, err)
+	assert.Equal(t, 1, fake.requestCount(http.MethodGet))
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := retryPolicy{baseDelay: 100 * time.Millisecond, maxDelay: time.Second}
+
+	for attempt, bounds := range map[int][2]time.Duration{
+		0:  {50 * time.Millisecond, 100 * time.Millisecond},
+		3:  {400 * time.Millisecond, 800 * time.Millisecond},
+		10: {500 * time.Millisecond, time.Second},
+		64: {500 * time.Millisecond, time.Second},
+	} {
+		delay := policy.delay(attempt, nil)
+		assert.GreaterOrEqual(t, delay, bounds[0], "attempt %d", attempt)
+		assert.LessOrEqual(t, delay, bounds[1], "attempt %d", attempt)
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": {"7"}}}
+	assert.Equal(t, 7*time.Second, policy.delay(0, resp))
+
+	resp.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	delay := policy.delay(0, resp)
+	assert.Greater(t, delay, 59*time.Minute)
+	assert.LessOrEqual(t, delay, time.Hour)
+}
+
+func TestConfigRetrySettings(t *testing.T) {
+	logicalBackend, logicalStorage := getTestBackend(t)
+
+	request := func(operation logical.Operation, data map[string]interface{}) *logical.Response {
+		resp, err := logicalBackend.HandleRequest(context.Background(), &logical.Request{
+			Operation: operation,
+			Path:      configStoragePath,
+			Data:      data,
+			Storage:   logicalStorage,
+		})
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := request(logical.CreateOperation, map[string]interface{}{
+		"ccloud_api_key_id":     fakeRootKeyId,
+		"ccloud_api_key_secret": fakeRootKeySecret,
+		"url":                   url,
+		"verify_connection":     false,
+	})
+	require.Nil(t, resp)
+
+	resp = request(logical.ReadOperation, nil)
+	assert.Equal(t, defaultMaxRetries, resp.Data["max_retries"])
+	assert.Equal(t, int64(defaultRetryBudget.Seconds()), resp.Data["retry_budget"])
+
+	resp = request(logical.UpdateOperation, map[string]interface{}{
+		"max_retries": 0,
+	})
+	require.Nil(t, resp)
+
+	config, err := getConfig(context.Background(), logicalStorage, defaultConnectionName)
+	require.NoError(t, err)
+	assert.Equal(t, 0, config.MaxRetries, "disabling retries must be persisted")
+
+	resp = request(logical.UpdateOperation, map[string]interface{}{
+		"max_retries": -1,
+	})
+	require.True(t, resp.IsError())
+}
@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"time"
+)
+
+// defaultRequestTimeout bounds every call to Confluent Cloud, so a hung
+// connection cannot hold a Vault request open indefinitely
+const defaultRequestTimeout = 30 * time.Second
+
+// newHTTPClient creates the HTTP client used for all calls to Confluent Cloud
+// and the identity provider, applying the transport settings of a
+// connection.
+func newHTTPClient(config *ccloudConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: config.TLSServerName,
+	}
+
+	if config.CACert != "" {
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM([]byte(config.CACert)) {
+			return nil, errors.New("ca_cert does not contain a valid PEM encoded certificate")
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if config.ProxyURL != "" {
+		proxyURL, err := neturl.Parse(config.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy_url: %w", err)
+		}
+		if proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy_url %q: scheme and host are required", config.ProxyURL)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if config.MaxIdleConns < 0 {
+		return nil, errors.New("max_idle_conns cannot be negative")
+	}
+	if config.MaxIdleConns > 0 {
+		transport.MaxIdleConns = config.MaxIdleConns
+		transport.MaxIdleConnsPerHost = config.MaxIdleConns
+	}
+
+	if config.RequestTimeout < 0 {
+		return nil, errors.New("request_timeout cannot be negative")
+	}
+	timeout := config.RequestTimeout
+	if timeout == 0 {
+		timeout = defaultRequestTimeout
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}, nil
+}
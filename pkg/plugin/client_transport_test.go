@@ -0,0 +1,134 @@
+package plugin
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigWithCACertTrustsServer(t *testing.T) {
+	fake := newFakeCCloud(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(fake.serveHTTP))
+	t.Cleanup(server.Close)
+
+	caCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	logicalBackend, logicalStorage := getTestBackend(t)
+
+	writeConfig := func(data map[string]interface{}) *logical.Response {
+		resp, err := logicalBackend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      configStoragePath,
+			Data:      data,
+			Storage:   logicalStorage,
+		})
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := writeConfig(map[string]interface{}{
+		"ccloud_api_key_id":     fakeRootKeyId,
+		"ccloud_api_key_secret": fakeRootKeySecret,
+		"url":                   server.URL,
+	})
+	require.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "certificate")
+
+	resp = writeConfig(map[string]interface{}{
+		"ccloud_api_key_id":     fakeRootKeyId,
+		"ccloud_api_key_secret": fakeRootKeySecret,
+		"url":                   server.URL,
+		"ca_cert":               caCert,
+	})
+	require.Nil(t, resp)
+
+	resp, err := logicalBackend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      configStoragePath,
+		Storage:   logicalStorage,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, caCert, resp.Data["ca_cert"])
+}
+
+func TestConfigRejectsInvalidTransportSettings(t *testing.T) {
+	logicalBackend, logicalStorage := getTestBackend(t)
+
+	for name, data := range map[string]map[string]interface{}{
+		"ca_cert":        {"ca_cert": "not a certificate"},
+		"proxy_url":      {"proxy_url": "proxy.example.com:3128"},
+		"max_idle_conns": {"max_idle_conns": -1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data["ccloud_api_key_id"] = fakeRootKeyId
+			data["ccloud_api_key_secret"] = fakeRootKeySecret
+			data["url"] = url
+			data["verify_connection"] = false
+
+			resp, err := logicalBackend.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.CreateOperation,
+				Path:      configStoragePath,
+				Data:      data,
+				Storage:   logicalStorage,
+			})
+			require.NoError(t, err)
+			require.True(t, resp.IsError())
+			assert.Contains(t, resp.Error().Error(), name)
+		})
+	}
+}
+
+func TestClientSendsRequestsThroughProxy(t *testing.T) {
+	fake := newFakeCCloud(t)
+
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.Host)
+		fake.serveHTTP(w, r)
+	}))
+	t.Cleanup(proxy.Close)
+
+	client, err := newClient(&ccloudConfig{
+		ApiKeyId:     fakeRootKeyId,
+		ApiKeySecret: fakeRootKeySecret,
+		URL:          "http://ccloud.example.invalid",
+		ProxyURL:     proxy.URL,
+	}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, client.VerifyConnection(context.Background()))
+	assert.Equal(t, []string{"ccloud.example.invalid"}, proxied)
+}
+
+func TestClientRequestsTimeOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	client, err := newClient(&ccloudConfig{
+		ApiKeyId:       fakeRootKeyId,
+		ApiKeySecret:   fakeRootKeySecret,
+		URL:            server.URL,
+		RequestTimeout: 100 * time.Millisecond,
+	}, nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	err = client.VerifyConnection(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to reach")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
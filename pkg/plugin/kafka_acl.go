@@ -0,0 +1,147 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	apikeys "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
+)
+
+// kafkaClusterResourceName is the name of the only CLUSTER resource
+const kafkaClusterResourceName = "kafka-cluster"
+
+// Values Kafka accepts for the fields of an ACL
+var kafkaACLValues = map[string][]string{
+	"resource_type": {"CLUSTER", "GROUP", "TOPIC", "TRANSACTIONAL_ID"},
+	"pattern_type":  {"LITERAL", "PREFIXED"},
+	"operation": {
+		"ALL", "ALTER", "ALTER_CONFIGS", "CLUSTER_ACTION", "CREATE", "DELETE",
+		"DESCRIBE", "DESCRIBE_CONFIGS", "IDEMPOTENT_WRITE", "READ", "WRITE",
+	},
+	"permission": {"ALLOW", "DENY"},
+}
+
+// kafkaACL is a Kafka ACL a role creates for the owner of each key
+type kafkaACL struct {
+	ResourceType string `json:"resource_type"`
+	ResourceName string `json:"resource_name"`
+	PatternType  string `json:"pattern_type"`
+	Operation    string `json:"operation"`
+	Permission   string `json:"permission"`
+	Host         string `json:"host"`
+}
+
+// parseKafkaACLs parses the acls field of a role. ACLs are objects with a
+// resource_type, resource_name, pattern_type, operation, permission and host.
+// The pattern type defaults to LITERAL, the permission to ALLOW and the host
+// to "*".
+func parseKafkaACLs(raw []interface{}) ([]kafkaACL, error) {
+	acls := make([]kafkaACL, 0, len(raw))
+	for i, item := range raw {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("ACL %d must be an object", i)
+		}
+
+		field := func(name, fallback string) string {
+			value, _ := fields[name].(string)
+			if value == "" {
+				return fallback
+			}
+			return value
+		}
+
+		acl := kafkaACL{
+			ResourceType: strings.ToUpper(field("resource_type", "")),
+			ResourceName: field("resource_name", ""),
+			PatternType:  strings.ToUpper(field("pattern_type", "LITERAL")),
+			Operation:    strings.ToUpper(field("operation", "")),
+			Permission:   strings.ToUpper(field("permission", "ALLOW")),
+			Host:         field("host", "*"),
+		}
+
+		if err := acl.validate(); err != nil {
+			return nil, fmt.Errorf("ACL %d: %w", i, err)
+		}
+		acls = append(acls, acl)
+	}
+
+	return acls, nil
+}
+
+func (a kafkaACL) validate() error {
+	for _, field := range []struct{ name, value string }{
+		{"resource_type", a.ResourceType},
+		{"pattern_type", a.PatternType},
+		{"operation", a.Operation},
+		{"permission", a.Permission},
+	} {
+		allowed := kafkaACLValues[field.name]
+		if !slices.Contains(allowed, field.value) {
+			return fmt.Errorf("%s must be one of %s, got %q", field.name, strings.Join(allowed, ", "), field.value)
+		}
+	}
+
+	if a.ResourceName == "" {
+		return errors.New("resource_name is required")
+	}
+	if a.ResourceType == "CLUSTER" && a.ResourceName != kafkaClusterResourceName {
+		return fmt.Errorf("resource_name of a CLUSTER ACL must be %q", kafkaClusterResourceName)
+	}
+
+	return nil
+}
+
+// leaseACLs are the ACLs created for the key of a lease, with where they
+// were created, so they can be deleted even if the role changes. They are
+// managed with the Kafka API key of the role, which is not stored with the
+// lease.
+type leaseACLs struct {
+	Endpoint  string     `json:"endpoint"`
+	ClusterId string     `json:"cluster_id"`
+	Principal string     `json:"principal"`
+	ACLs      []kafkaACL `json:"acls"`
+}
+
+// kafkaACLAuth returns the Kafka API key the role manages the ACLs of its
+// leases with
+func (r *apikeyRoleEntry) kafkaACLAuth() apikeys.BasicAuth {
+	return apikeys.BasicAuth{UserName: r.ACLApiKeyId, Password: r.ACLApiKeySecret}
+}
+
+// toInternalData encodes the ACLs for the internal data of a secret
+func (l *leaseACLs) toInternalData() (map[string]interface{}, error) {
+	raw, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// leaseACLsFromInternalData reads the ACLs of a lease from its internal
+// data. It returns nil if the lease has none.
+func leaseACLsFromInternalData(internalData map[string]interface{}) (*leaseACLs, error) {
+	raw, ok := internalData["kafka_acls"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var acls leaseACLs
+	if err := json.Unmarshal(encoded, &acls); err != nil {
+		return nil, fmt.Errorf("invalid value for kafka_acls in secret internal data: %w", err)
+	}
+	return &acls, nil
+}
@@ -0,0 +1,117 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Kinds of API keys a role can generate
+const (
+	keyKindCloud          = "cloud"
+	keyKindKafka          = "kafka"
+	keyKindSchemaRegistry = "schema_registry"
+	keyKindKsqlDB         = "ksqldb"
+	keyKindFlink          = "flink"
+)
+
+const ksqlDBClusterPrefix = "lksqlc-"
+
+// keyKindSpec describes the resource keys of a kind are scoped to
+type keyKindSpec struct {
+	// resourceName names the resource in messages, if keys are scoped to one
+	resourceName string
+	// resourcePrefix is the ID prefix of the resource, if it has one
+	resourcePrefix string
+}
+
+var keyKinds = map[string]keyKindSpec{
+	keyKindCloud:          {},
+	keyKindKafka:          {resourceName: "Kafka cluster", resourcePrefix: kafkaClusterPrefix},
+	keyKindSchemaRegistry: {resourceName: "Schema Registry cluster", resourcePrefix: schemaRegistryClusterPrefix},
+	keyKindKsqlDB:         {resourceName: "ksqlDB cluster", resourcePrefix: ksqlDBClusterPrefix},
+	keyKindFlink:          {resourceName: "Flink region"},
+}
+
+// keyKindNames returns the names of all key kinds, sorted
+func keyKindNames() []string {
+	names := make([]string, 0, len(keyKinds))
+	for name := range keyKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// keyKind returns the kind of keys the role generates. Roles written before
+// kinds were introduced generate Cloud API keys if they have no resource, and
+// otherwise keys for the kind of cluster their resource refers to.
+func (r *apikeyRoleEntry) keyKind() string {
+	if r.KeyKind != "" {
+		return r.KeyKind
+	}
+
+	switch {
+	case r.Resource == "":
+		return keyKindCloud
+	case strings.HasPrefix(r.Resource, schemaRegistryClusterPrefix):
+		return keyKindSchemaRegistry
+	case strings.HasPrefix(r.Resource, ksqlDBClusterPrefix):
+		return keyKindKsqlDB
+	default:
+		return keyKindKafka
+	}
+}
+
+// validateKeyKind checks that the role has the fields its key kind requires
+func (r *apikeyRoleEntry) validateKeyKind() error {
+	return validateKeyKind(r.KeyKind, r.Resource, r.ResourceEnv)
+}
+
+// validateKeyKind checks that a key kind is known and that the resource
+// fields match what keys of the kind require
+func validateKeyKind(kind, resource, resourceEnv string) error {
+	spec, ok := keyKinds[kind]
+	if !ok {
+		return fmt.Errorf("key_kind must be one of %s", strings.Join(keyKindNames(), ", "))
+	}
+
+	if spec.resourceName == "" {
+		if resource != "" || resourceEnv != "" {
+			return fmt.Errorf("resource and resource_env cannot be set for %s keys", kind)
+		}
+		return nil
+	}
+
+	if resource == "" {
+		return fmt.Errorf("resource is required for %s keys", kind)
+	}
+	if resourceEnv == "" {
+		return fmt.Errorf("resource_env is required for %s keys", kind)
+	}
+	if spec.resourcePrefix != "" && !strings.HasPrefix(resource, spec.resourcePrefix) {
+		return fmt.Errorf("resource of %s keys must be a %s (%s), got %q", kind, spec.resourceName, spec.resourcePrefix, resource)
+	}
+
+	return nil
+}
+
+// credentialData returns the response data of a key, with the client
+// settings that are specific to its kind
+func credentialData(kind, keyId, secret string) map[string]interface{} {
+	data := map[string]interface{}{
+		"key_id":   keyId,
+		"secret":   secret,
+		"key_kind": kind,
+	}
+
+	switch kind {
+	case keyKindKafka:
+		data["sasl.jaas.config"] = "org.apache.kafka.common.security.plain.PlainLoginModule required username='" + keyId + "' password='" + secret + "';"
+	case keyKindSchemaRegistry, keyKindKsqlDB:
+		data["basic.auth.credentials.source"] = "USER_INFO"
+		data["basic.auth.user.info"] = keyId + ":" + secret
+	}
+
+	return data
+}
@@ -0,0 +1,384 @@
+package plugin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	poolStoragePrefix = "pool/"
+
+	// defaultPoolMinAge is how long a pooled key ages before it is handed
+	// out, so brokers accept it right away
+	defaultPoolMinAge = time.Minute
+
+	// poolRefillQueueSize bounds the refills waiting for the pool worker
+	poolRefillQueueSize = 64
+)
+
+// keyPoolEntry holds the keys created ahead of time for a role
+type keyPoolEntry struct {
+	// RoleFingerprint identifies the role settings the keys were created
+	// with. Keys created with other settings are deleted.
+	RoleFingerprint string `json:"role_fingerprint"`
+
+	// Connection is the connection the keys were created through
+	Connection string      `json:"connection,omitempty"`
+	Keys       []pooledKey `json:"keys,omitempty"`
+}
+
+// pooledKey is a key waiting in a role's pool
+type pooledKey struct {
+	KeyId     string    `json:"key_id"`
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// poolMinAge returns how long the role's pooled keys age before they are
+// handed out
+func (r *apikeyRoleEntry) poolMinAge() time.Duration {
+	if r.PoolMinAge > 0 {
+		return r.PoolMinAge
+	}
+	return defaultPoolMinAge
+}
+
+// poolFingerprint identifies the settings of a role that its keys are
+// created with
+func (r *apikeyRoleEntry) poolFingerprint() string {
+	settings, _ := json.Marshal([]string{
+		normalizeConnection(r.Connection),
+		r.keyKind(),
+		r.Owner,
+		r.OwnerEnv,
+		r.Resource,
+		r.ResourceEnv,
+		r.displayNameTemplate(),
+		r.DescriptionTemplate,
+		r.KeyDescription,
+	})
+	sum := sha256.Sum256(settings)
+	return hex.EncodeToString(sum[:])
+}
+
+// startPoolWorker starts the worker that refills pools in the background,
+// once. It is started when the backend is initialized on a node that writes
+// storage, and stops when the backend is cleaned up.
+func (b *ccloudBackend) startPoolWorker(s logical.Storage) {
+	b.poolWorker.Do(func() {
+		ctx := b.poolWorkerCtx
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case name := <-b.poolRefills:
+					if err := b.refillPool(ctx, s, name); err != nil && ctx.Err() == nil {
+						b.Logger().Error("failed to refill key pool", "role", name, "error", err)
+					}
+				}
+			}
+		}()
+	})
+}
+
+// clean stops the pool worker
+func (b *ccloudBackend) clean(ctx context.Context) {
+	b.stopPoolWorker()
+}
+
+// schedulePoolRefill asks the pool worker to refill a role's pool. Requests
+// made while the queue is full are dropped; the periodic function schedules
+// all pools again. Nodes that do not write storage run no pool worker, so
+// they schedule nothing.
+func (b *ccloudBackend) schedulePoolRefill(name string) {
+	if !b.writesStorage() {
+		return
+	}
+
+	select {
+	case b.poolRefills <- name:
+	default:
+	}
+}
+
+// schedulePoolRefills schedules a refill of every pool, and of every role
+// that should have one
+func (b *ccloudBackend) schedulePoolRefills(ctx context.Context, s logical.Storage) error {
+	if !b.writesStorage() {
+		return nil
+	}
+
+	pools, err := s.List(ctx, poolStoragePrefix)
+	if err != nil {
+		return err
+	}
+
+	roles, err := s.List(ctx, "role/")
+	if err != nil {
+		return err
+	}
+
+	scheduled := make(map[string]bool, len(pools))
+	for _, name := range pools {
+		scheduled[name] = true
+		b.schedulePoolRefill(name)
+	}
+
+	for _, name := range roles {
+		if scheduled[name] || strings.HasSuffix(name, "/") {
+			continue
+		}
+
+		role, err := b.getRole(ctx, s, name)
+		if err != nil {
+			return err
+		}
+		if role != nil && role.PoolSize > 0 {
+			b.schedulePoolRefill(name)
+		}
+	}
+
+	return nil
+}
+
+// refillPool creates keys until the role's pool is full. Keys of a pool
+// whose role was deleted or changed are deleted first. Keys are created
+// without holding poolLock, so credentials can be handed out meanwhile.
+func (b *ccloudBackend) refillPool(ctx context.Context, s logical.Storage, name string) error {
+	for {
+		role, full, err := b.preparePool(ctx, s, name)
+		if err != nil || full {
+			return err
+		}
+
+		client, err := b.getClient(ctx, s, role.Connection)
+		if err != nil {
+			return err
+		}
+
+		templateData, err := newKeyTemplateData(name, "", "", "", "")
+		if err != nil {
+			return err
+		}
+
+		displayName, err := renderKeyTemplate(role.displayNameTemplate(), templateData)
+		if err != nil {
+			return fmt.Errorf("error rendering display name template: %w", err)
+		}
+
+		description := fmt.Sprintf("Pooled key for role: %s (source=Vault CC plugin)", name)
+		if role.KeyDescription != "" {
+			description = role.KeyDescription
+		}
+		if role.DescriptionTemplate != "" {
+			description, err = renderKeyTemplate(role.DescriptionTemplate, templateData)
+			if err != nil {
+				return fmt.Errorf("error rendering description template: %w", err)
+			}
+		}
+
+		keyId, secret, err := client.CreateApiKey(ctx, role.Owner, role.OwnerEnv, role.Resource, role.ResourceEnv, displayName, description)
+		if err != nil {
+			return err
+		}
+
+		added, err := b.addPooledKey(ctx, s, name, role.poolFingerprint(), pooledKey{KeyId: keyId, Secret: secret, CreatedAt: time.Now()})
+		if err != nil || !added {
+			// the role changed while the key was created
+			return errors.Join(err, b.rollbackApiKey(ctx, client, keyId))
+		}
+	}
+}
+
+// preparePool deletes the pooled keys a role can no longer use and reports
+// whether its pool is full
+func (b *ccloudBackend) preparePool(ctx context.Context, s logical.Storage, name string) (*apikeyRoleEntry, bool, error) {
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+
+	role, err := b.getRole(ctx, s, name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	pool, err := getKeyPool(ctx, s, name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if role == nil || role.PoolSize == 0 {
+		return nil, true, b.drainPoolLocked(ctx, s, name, pool, 0)
+	}
+
+	if pool.RoleFingerprint != role.poolFingerprint() {
+		if err := b.drainPoolLocked(ctx, s, name, pool, 0); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if len(pool.Keys) > role.PoolSize {
+		return nil, true, b.drainPoolLocked(ctx, s, name, pool, role.PoolSize)
+	}
+
+	return role, len(pool.Keys) == role.PoolSize, nil
+}
+
+// addPooledKey adds a key to a role's pool, unless the role changed or the
+// pool filled up since the key was requested
+func (b *ccloudBackend) addPooledKey(ctx context.Context, s logical.Storage, name, fingerprint string, key pooledKey) (bool, error) {
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+
+	role, err := b.getRole(ctx, s, name)
+	if err != nil || role == nil || role.poolFingerprint() != fingerprint {
+		return false, err
+	}
+
+	pool, err := getKeyPool(ctx, s, name)
+	if err != nil {
+		return false, err
+	}
+
+	if len(pool.Keys) >= role.PoolSize {
+		return false, nil
+	}
+
+	pool.RoleFingerprint = fingerprint
+	pool.Connection = role.Connection
+	pool.Keys = append(pool.Keys, key)
+	if err := setKeyPool(ctx, s, name, pool); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// takePooledKey removes the oldest key that has aged in from a role's pool,
+// and schedules a refill. It returns nil if no key is ready.
+func (b *ccloudBackend) takePooledKey(ctx context.Context, s logical.Storage, name string, role *apikeyRoleEntry) (*ccloudClusterApiKey, error) {
+	defer b.schedulePoolRefill(name)
+
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+
+	pool, err := getKeyPool(ctx, s, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if pool.RoleFingerprint != role.poolFingerprint() {
+		return nil, nil
+	}
+
+	cutoff := time.Now().Add(-role.poolMinAge())
+	for i, key := range pool.Keys {
+		if key.CreatedAt.After(cutoff) {
+			continue
+		}
+
+		pool.Keys = append(pool.Keys[:i], pool.Keys[i+1:]...)
+		if err := setKeyPool(ctx, s, name, pool); err != nil {
+			return nil, err
+		}
+
+		return &ccloudClusterApiKey{KeyId: key.KeyId, Secret: key.Secret}, nil
+	}
+
+	return nil, nil
+}
+
+// drainPool deletes the pooled keys of a role that was deleted, changed or
+// no longer pools keys
+func (b *ccloudBackend) drainPool(ctx context.Context, s logical.Storage, name string) error {
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+
+	pool, err := getKeyPool(ctx, s, name)
+	if err != nil {
+		return err
+	}
+
+	return b.drainPoolLocked(ctx, s, name, pool, 0)
+}
+
+// drainPoolLocked deletes pooled keys beyond keep. Keys that cannot be
+// deleted stay in the pool, marked as unusable, and are deleted by a later
+// refill. A pool without keys is removed from storage. The caller must hold
+// poolLock.
+func (b *ccloudBackend) drainPoolLocked(ctx context.Context, s logical.Storage, name string, pool *keyPoolEntry, keep int) error {
+	if len(pool.Keys) <= keep {
+		if len(pool.Keys) == 0 {
+			return s.Delete(ctx, poolStoragePrefix+name)
+		}
+		return nil
+	}
+
+	var errs []error
+	var remaining []pooledKey
+	for i, key := range pool.Keys {
+		if i < keep {
+			remaining = append(remaining, key)
+			continue
+		}
+
+		client, err := b.getClient(ctx, s, pool.Connection)
+		if err == nil {
+			err = client.DeleteApiKey(ctx, key.KeyId)
+		}
+		if err != nil && !errors.Is(err, errCCloudNotFound) {
+			errs = append(errs, err)
+			remaining = append(remaining, key)
+		}
+	}
+
+	if len(errs) > 0 && keep == 0 {
+		// the remaining keys no longer match any role settings
+		pool.RoleFingerprint = ""
+	}
+	pool.Keys = remaining
+
+	if len(pool.Keys) == 0 {
+		errs = append(errs, s.Delete(ctx, poolStoragePrefix+name))
+	} else {
+		errs = append(errs, setKeyPool(ctx, s, name, pool))
+	}
+
+	return errors.Join(errs...)
+}
+
+// setKeyPool stores the pool of a role
+func setKeyPool(ctx context.Context, s logical.Storage, name string, pool *keyPoolEntry) error {
+	entry, err := logical.StorageEntryJSON(poolStoragePrefix+name, pool)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// getKeyPool reads the pool of a role. A role without a pool has an empty
+// one.
+func getKeyPool(ctx context.Context, s logical.Storage, name string) (*keyPoolEntry, error) {
+	entry, err := s.Get(ctx, poolStoragePrefix+name)
+	if err != nil {
+		return nil, err
+	}
+
+	var pool keyPoolEntry
+	if entry != nil {
+		if err := entry.DecodeJSON(&pool); err != nil {
+			return nil, err
+		}
+	}
+
+	return &pool, nil
+}
@@ -0,0 +1,175 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForPool waits until the pool of a role holds size keys and returns it
+func waitForPool(t *testing.T, b *ccloudBackend, s logical.Storage, size int) *keyPoolEntry {
+	t.Helper()
+
+	var pool *keyPoolEntry
+	require.Eventually(t, func() bool {
+		b.poolLock.Lock()
+		defer b.poolLock.Unlock()
+
+		var err error
+		pool, err = getKeyPool(context.Background(), s, roleName)
+		require.NoError(t, err)
+		return len(pool.Keys) == size
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return pool
+}
+
+// agePool makes the keys in the pool of a role old enough to be handed out
+func agePool(t *testing.T, b *ccloudBackend, s logical.Storage) {
+	t.Helper()
+
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+
+	pool, err := getKeyPool(context.Background(), s, roleName)
+	require.NoError(t, err)
+	for i := range pool.Keys {
+		pool.Keys[i].CreatedAt = time.Now().Add(-time.Hour)
+	}
+	require.NoError(t, setKeyPool(context.Background(), s, roleName, pool))
+}
+
+func TestKeyPoolHandsOutAgedKeys(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"key_kind":  "cloud",
+		"owner":     fakeRootKeyOwner,
+		"pool_size": 2,
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	pool := waitForPool(t, b, s, 2)
+	pooled := map[string]bool{pool.Keys[0].KeyId: true, pool.Keys[1].KeyId: true}
+
+	// keys that have not aged in are not handed out
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + roleName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.False(t, pooled[resp.Data["key_id"].(string)])
+
+	agePool(t, b, s)
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + roleName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.True(t, pooled[resp.Data["key_id"].(string)])
+
+	// the pool is refilled in the background
+	pool = waitForPool(t, b, s, 2)
+	assert.Equal(t, 1+2+2, fake.keyCount())
+}
+
+func TestKeyPoolIsDrainedWhenRoleChanges(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"key_kind":  "cloud",
+		"owner":     fakeRootKeyOwner,
+		"pool_size": 2,
+	})
+	require.NoError(t, err)
+	oldKeys := waitForPool(t, b, s, 2).Keys
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/" + roleName,
+		Data:      map[string]interface{}{"owner": "sa-other"},
+		Storage:   s,
+	})
+	require.NoError(t, err)
+
+	for _, key := range oldKeys {
+		assert.False(t, fake.hasKey(key.KeyId))
+	}
+	for _, key := range waitForPool(t, b, s, 2).Keys {
+		assert.Equal(t, "sa-other", fake.key(key.KeyId).Owner)
+	}
+
+	// a smaller pool is trimmed
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/" + roleName,
+		Data:      map[string]interface{}{"pool_size": 1},
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	waitForPool(t, b, s, 1)
+	assert.Equal(t, 2, fake.keyCount())
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "role/" + roleName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, fake.keyCount())
+
+	entry, err := s.Get(context.Background(), poolStoragePrefix+roleName)
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+}
+
+func TestKeyPoolIsRefilledOnlyByNodesThatWriteStorage(t *testing.T) {
+	fake := newFakeCCloud(t)
+	system := logical.TestSystemView()
+	system.ReplicationStateVal = consts.ReplicationPerformanceStandby
+	b, s := newTestBackend(t, system)
+	require.NoError(t, b.Initialize(context.Background(), &logical.InitializationRequest{Storage: s}))
+	fake.configure(t, b, s)
+
+	_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"key_kind":  "cloud",
+		"owner":     fakeRootKeyOwner,
+		"pool_size": 2,
+	})
+	require.NoError(t, err)
+	require.NoError(t, b.schedulePoolRefills(context.Background(), s))
+
+	// nothing was scheduled for a pool worker that does not run here
+	assert.Empty(t, b.poolRefills)
+	assert.Equal(t, 1, fake.keyCount())
+}
+
+func TestRoleWriteValidatesPoolSize(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	for _, tc := range []struct {
+		data     map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"key_kind": "cloud", "owner": fakeRootKeyOwner, "pool_size": -1}, "cannot be negative"},
+		{map[string]interface{}{"key_kind": "cloud", "owner": fakeRootKeyOwner, "pool_size": 1, "multi_use_key": true}, "pool_size cannot be combined"},
+		{map[string]interface{}{"key_kind": "cloud", "dynamic_service_account": true, "pool_size": 1}, "pool_size cannot be combined"},
+	} {
+		resp, err := testTokenRoleCreate(t, b, s, roleName, tc.data)
+		require.NoError(t, err)
+		require.True(t, resp.IsError(), tc.expected)
+		assert.Contains(t, resp.Error().Error(), tc.expected)
+	}
+}
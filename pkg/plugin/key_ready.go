@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultReadyTimeout is how long credential requests of a role with
+	// wait_for_ready wait for a new key to be accepted
+	defaultReadyTimeout = 2 * time.Minute
+
+	// readyPollMinInterval and readyPollMaxInterval bound the delay between
+	// checks of a new key, which doubles after every check
+	readyPollMinInterval = 250 * time.Millisecond
+	readyPollMaxInterval = 5 * time.Second
+)
+
+// errKeyNotReady is returned when a new key is not accepted within the
+// role's ready_timeout
+var errKeyNotReady = errors.New("key not accepted in time")
+
+// readyTimeout returns how long credential requests wait for a new key to
+// be accepted
+func (r *apikeyRoleEntry) readyTimeout() time.Duration {
+	if r.ReadyTimeout > 0 {
+		return r.ReadyTimeout
+	}
+	return defaultReadyTimeout
+}
+
+// waitForKey checks a new key against the resource it is scoped to until
+// the key is accepted, or fails once the role's ready_timeout has passed
+func (b *ccloudBackend) waitForKey(ctx context.Context, client *ccloudAPIKeyClient, role *apikeyRoleEntry, keyId, secret string) error {
+	timeout := role.readyTimeout()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	probe, err := client.ReadyProbe(ctx, role.keyKind(), keyId, role.Resource, role.ResourceEnv)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	interval := readyPollMinInterval
+	var lastErr error
+	for {
+		accepted, err := client.KeyAccepted(ctx, probe, keyId, secret)
+		if accepted {
+			b.Logger().Debug("new key is accepted", "key_id", keyId, "probe", probe, "after", time.Since(start))
+			return nil
+		}
+		if err != nil && ctx.Err() == nil {
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			err := fmt.Errorf("%w: %s was not accepted by %s within %s", errKeyNotReady, keyId, probe, timeout)
+			if lastErr != nil {
+				err = fmt.Errorf("%w, last error: %w", err, lastErr)
+			}
+			return err
+		case <-time.After(interval):
+		}
+
+		interval = min(2*interval, readyPollMaxInterval)
+	}
+}
@@ -0,0 +1,89 @@
+package plugin
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/helper/template"
+)
+
+const (
+	// defaultDisplayNameTemplate names keys after the role they were
+	// created for
+	defaultDisplayNameTemplate = `vault-{{.RoleName}}-{{.RandomSuffix}}`
+
+	// defaultServiceAccountNameTemplate names dynamic service accounts after
+	// the role they were created for, within the 64 characters Confluent
+	// Cloud allows
+	defaultServiceAccountNameTemplate = `vault-{{.RoleName | truncate 40}}-{{.RandomSuffix}}`
+)
+
+// keyTemplateData is the data display name and description templates of a
+// role are rendered with
+type keyTemplateData struct {
+	RoleName     string
+	MountPoint   string
+	EntityID     string
+	EntityName   string
+	DisplayName  string
+	RequestTime  string
+	RandomSuffix string
+}
+
+// exampleKeyTemplateData is used to check that a template renders when a
+// role is written
+var exampleKeyTemplateData = keyTemplateData{
+	RoleName:     "role",
+	MountPoint:   "ccloud/",
+	EntityID:     "00000000-0000-0000-0000-000000000000",
+	EntityName:   "entity",
+	DisplayName:  "token",
+	RequestTime:  "2006-01-02T15:04:05Z",
+	RandomSuffix: "0123abcd",
+}
+
+// newKeyTemplateData returns the template data for a key created now
+func newKeyTemplateData(roleName, mountPoint, entityID, entityName, displayName string) (keyTemplateData, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return keyTemplateData{}, fmt.Errorf("error generating random suffix: %w", err)
+	}
+
+	return keyTemplateData{
+		RoleName:     roleName,
+		MountPoint:   mountPoint,
+		EntityID:     entityID,
+		EntityName:   entityName,
+		DisplayName:  displayName,
+		RequestTime:  time.Now().UTC().Format(time.RFC3339),
+		RandomSuffix: hex.EncodeToString(suffix),
+	}, nil
+}
+
+// renderKeyTemplate renders a display name or description template
+func renderKeyTemplate(raw string, data keyTemplateData) (string, error) {
+	tmpl, err := template.NewTemplate(template.Template(raw))
+	if err != nil {
+		return "", err
+	}
+
+	rendered, err := tmpl.Generate(data)
+	if err != nil {
+		return "", err
+	}
+
+	if rendered == "" {
+		return "", errors.New("template rendered an empty string")
+	}
+
+	return rendered, nil
+}
+
+// validateKeyTemplate checks that a template can be rendered
+func validateKeyTemplate(raw string) error {
+	_, err := renderKeyTemplate(raw, exampleKeyTemplateData)
+	return err
+}
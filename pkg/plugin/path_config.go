@@ -4,56 +4,371 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/pluginidentityutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
 const (
-	configStoragePath = "config"
+	configStoragePath     = "config"
+	defaultConnectionName = "default"
 )
 
+// normalizeConnection maps an empty connection name to the default connection
+func normalizeConnection(connection string) string {
+	if connection == "" {
+		return defaultConnectionName
+	}
+	return connection
+}
+
+// connectionStoragePath returns the storage path of a connection's
+// configuration. The default connection is stored at "config".
+func connectionStoragePath(connection string) string {
+	connection = normalizeConnection(connection)
+	if connection == defaultConnectionName {
+		return configStoragePath
+	}
+	return configStoragePath + "/" + connection
+}
+
+// connectionFromStoragePath returns the connection a storage path belongs to
+func connectionFromStoragePath(path string) (string, bool) {
+	if path == configStoragePath {
+		return defaultConnectionName, true
+	}
+	if connection := strings.TrimPrefix(path, configStoragePath+"/"); connection != path && connection != "" {
+		return connection, true
+	}
+	return "", false
+}
+
+// reservedConnectionNames are the names under config/ that address other
+// endpoints, so a connection with one of them could not be managed
+var reservedConnectionNames = []string{"rotate-root"}
+
+// connectionName returns the connection addressed by a request
+func connectionName(data *framework.FieldData) string {
+	if connection, ok := data.GetOk("connection"); ok {
+		return normalizeConnection(connection.(string))
+	}
+	return defaultConnectionName
+}
+
 // ccloudConfig includes the minimum configuration
 // required to instantiate a new CCloud API client.
 type ccloudConfig struct {
+	// Version is the storage version the configuration was written with
+	Version int `json:"version,omitempty"`
+
 	ApiKeyId     string `json:"api_key_id"`
 	ApiKeySecret string `json:"api_key_secret"`
 	URL          string `json:"url"`
+
+	OAuthTokenURL     string   `json:"oauth_token_url,omitempty"`
+	OAuthClientId     string   `json:"oauth_client_id,omitempty"`
+	OAuthClientSecret string   `json:"oauth_client_secret,omitempty"`
+	OAuthScopes       []string `json:"oauth_scopes,omitempty"`
+	IdentityPoolId    string   `json:"identity_pool_id,omitempty"`
+	STSURL            string   `json:"sts_url,omitempty"`
+
+	pluginidentityutil.PluginIdentityTokenParams
+
+	CACert         string        `json:"ca_cert,omitempty"`
+	ProxyURL       string        `json:"proxy_url,omitempty"`
+	TLSServerName  string        `json:"tls_server_name,omitempty"`
+	RequestTimeout time.Duration `json:"request_timeout,omitempty"`
+	MaxIdleConns   int           `json:"max_idle_conns,omitempty"`
+
+	MaxRetries  int           `json:"max_retries"`
+	RetryBudget time.Duration `json:"retry_budget"`
+
+	RateLimit      float64       `json:"rate_limit,omitempty"`
+	RateLimitBurst int           `json:"rate_limit_burst,omitempty"`
+	MaxInFlight    int           `json:"max_in_flight,omitempty"`
+	RateLimitWait  time.Duration `json:"rate_limit_wait"`
+
+	RotationPeriod    time.Duration `json:"rotation_period,omitempty"`
+	RotationWindow    time.Duration `json:"rotation_window,omitempty"`
+	LastRotated       time.Time     `json:"last_rotated,omitempty"`
+	NextRotation      time.Time     `json:"next_rotation,omitempty"`
+	LastRotationError string        `json:"last_rotation_error,omitempty"`
+}
+
+// usesOAuth reports whether the backend authenticates with OAuth tokens
+// instead of a Cloud API key
+func (c *ccloudConfig) usesOAuth() bool {
+	return c.OAuthTokenURL != ""
+}
+
+// usesWorkloadIdentity reports whether the backend authenticates with Vault
+// plugin identity tokens instead of a Cloud API key
+func (c *ccloudConfig) usesWorkloadIdentity() bool {
+	return c.IdentityTokenAudience != ""
+}
+
+// stsURL returns the token exchange endpoint of the Confluent Cloud Security
+// Token Service
+func (c *ccloudConfig) stsURL() string {
+	if c.STSURL != "" {
+		return c.STSURL
+	}
+	return strings.TrimSuffix(c.URL, "/") + stsTokenPath
+}
+
+// connectionChanged reports whether the settings used to reach and
+// authenticate to Confluent Cloud differ from another configuration
+func (c *ccloudConfig) connectionChanged(other *ccloudConfig) bool {
+	return c.URL != other.URL ||
+		c.ApiKeyId != other.ApiKeyId ||
+		c.ApiKeySecret != other.ApiKeySecret ||
+		c.OAuthTokenURL != other.OAuthTokenURL ||
+		c.OAuthClientId != other.OAuthClientId ||
+		c.OAuthClientSecret != other.OAuthClientSecret ||
+		strings.Join(c.OAuthScopes, ",") != strings.Join(other.OAuthScopes, ",") ||
+		c.IdentityPoolId != other.IdentityPoolId ||
+		c.STSURL != other.STSURL ||
+		c.PluginIdentityTokenParams != other.PluginIdentityTokenParams ||
+		c.CACert != other.CACert ||
+		c.ProxyURL != other.ProxyURL ||
+		c.TLSServerName != other.TLSServerName ||
+		c.RequestTimeout != other.RequestTimeout
+}
+
+// validateAuth checks that exactly one way to authenticate to Confluent Cloud
+// is configured, and that it is complete
+func (c *ccloudConfig) validateAuth() error {
+	hasApiKey := c.ApiKeyId != "" || c.ApiKeySecret != ""
+
+	switch {
+	case c.usesOAuth() && c.usesWorkloadIdentity():
+		return errors.New("oauth_token_url cannot be combined with identity_token_audience")
+	case c.usesOAuth():
+		if hasApiKey {
+			return errors.New("ccloud_api_key_id and ccloud_api_key_secret cannot be combined with oauth_token_url")
+		}
+		if c.OAuthClientId == "" || c.OAuthClientSecret == "" || c.IdentityPoolId == "" {
+			return errors.New("oauth_client_id, oauth_client_secret and identity_pool_id are required with oauth_token_url")
+		}
+	case c.usesWorkloadIdentity():
+		if hasApiKey {
+			return errors.New("ccloud_api_key_id and ccloud_api_key_secret cannot be combined with identity_token_audience")
+		}
+		if c.IdentityPoolId == "" {
+			return errors.New("identity_pool_id is required with identity_token_audience")
+		}
+	}
+
+	return nil
+}
+
+// scheduleRotation sets the next rotation time based on the last rotation, or
+// on the given time if the key has never been rotated.
+func (c *ccloudConfig) scheduleRotation(now time.Time) {
+	if c.RotationPeriod <= 0 {
+		c.NextRotation = time.Time{}
+		return
+	}
+
+	base := c.LastRotated
+	if base.IsZero() {
+		base = now
+	}
+	c.NextRotation = base.Add(c.RotationPeriod)
 }
 
 // pathConfig extends the Vault API with a `/config` endpoint for the backend.
+// Additional named connections are configured at `/config/<connection>`.
 func pathConfig(b *ccloudBackend) *framework.Path {
-	return &framework.Path{
-		Pattern: "config",
-		Fields: map[string]*framework.FieldSchema{
-			"ccloud_api_key_id": {
-				Type:        framework.TypeString,
-				Description: "The API Key ID to access Confluent Cloud API",
-				Required:    true,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "Confluent Cloud API Key ID",
-					Sensitive: false,
-				},
-			},
-			"ccloud_api_key_secret": {
-				Type:        framework.TypeString,
-				Description: "The API Key Secret to access Confluent Cloud API",
-				Required:    true,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "Confluent Cloud API Key Secret",
-					Sensitive: true,
-				},
-			},
-			"url": {
-				Type:        framework.TypeString,
-				Description: "The URL for the Confluent Cloud API",
-				Required:    false,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "URL",
-					Sensitive: false,
-				},
+	fields := map[string]*framework.FieldSchema{
+		"connection": {
+			Type:        framework.TypeLowerCaseString,
+			Description: "Name of the connection. If not set, the default connection is used.",
+		},
+		"ccloud_api_key_id": {
+			Type:        framework.TypeString,
+			Description: "The API Key ID to access Confluent Cloud API. Required unless OAuth is configured.",
+			Required:    false,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Confluent Cloud API Key ID",
+				Sensitive: false,
 			},
 		},
+		"ccloud_api_key_secret": {
+			Type:        framework.TypeString,
+			Description: "The API Key Secret to access Confluent Cloud API. Required unless OAuth is configured.",
+			Required:    false,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Confluent Cloud API Key Secret",
+				Sensitive: true,
+			},
+		},
+		"url": {
+			Type:        framework.TypeString,
+			Description: "The URL for the Confluent Cloud API",
+			Required:    false,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "URL",
+				Sensitive: false,
+			},
+		},
+		"oauth_token_url": {
+			Type:        framework.TypeString,
+			Description: "Token endpoint of the identity provider. If set, the backend authenticates to Confluent Cloud with OAuth client credentials instead of a Cloud API key.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "OAuth Token URL",
+			},
+		},
+		"oauth_client_id": {
+			Type:        framework.TypeString,
+			Description: "OAuth client ID registered with the identity provider",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "OAuth Client ID",
+			},
+		},
+		"oauth_client_secret": {
+			Type:        framework.TypeString,
+			Description: "OAuth client secret registered with the identity provider",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "OAuth Client Secret",
+				Sensitive: true,
+			},
+		},
+		"oauth_scopes": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: "Scopes to request from the identity provider",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "OAuth Scopes",
+			},
+		},
+		"identity_pool_id": {
+			Type:        framework.TypeString,
+			Description: "Confluent Cloud identity pool the OAuth client or the plugin identity is mapped to",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Identity Pool ID",
+			},
+		},
+		"sts_url": {
+			Type:        framework.TypeString,
+			Description: "Token exchange endpoint of the Confluent Cloud Security Token Service. Defaults to the sts/v1/oauth2/token endpoint of the Confluent Cloud API URL.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "STS URL",
+			},
+		},
+		"ca_cert": {
+			Type:        framework.TypeString,
+			Description: "PEM encoded CA certificates to trust in addition to the system roots when connecting to Confluent Cloud and the identity provider",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "CA Certificate",
+			},
+		},
+		"proxy_url": {
+			Type:        framework.TypeString,
+			Description: "URL of the proxy to send requests to Confluent Cloud and the identity provider through. If not set, the proxy environment variables of the Vault server are used.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Proxy URL",
+			},
+		},
+		"tls_server_name": {
+			Type:        framework.TypeString,
+			Description: "Server name to verify the TLS certificate against, if it differs from the host of the URL",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "TLS Server Name",
+			},
+		},
+		"request_timeout": {
+			Type:        framework.TypeDurationSecond,
+			Description: "Timeout of a single request to Confluent Cloud or the identity provider. Defaults to 30 seconds.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Request Timeout",
+			},
+		},
+		"max_idle_conns": {
+			Type:        framework.TypeInt,
+			Description: "Maximum number of idle connections kept open to Confluent Cloud. If not set or set to 0, the Go default is used.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Max Idle Connections",
+			},
+		},
+		"max_retries": {
+			Type:        framework.TypeInt,
+			Default:     defaultMaxRetries,
+			Description: "Maximum number of times a failed call to Confluent Cloud is retried. Set to 0 to disable retries.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Max Retries",
+			},
+		},
+		"retry_budget": {
+			Type:        framework.TypeDurationSecond,
+			Default:     int(defaultRetryBudget.Seconds()),
+			Description: "Maximum time spent retrying a failed call to Confluent Cloud. If set to 0, retries are only limited by max_retries.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Retry Budget",
+			},
+		},
+		"rate_limit": {
+			Type:        framework.TypeFloat,
+			Description: "Maximum number of calls per second to Confluent Cloud. If not set or set to 0, calls are not rate limited.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Rate Limit",
+			},
+		},
+		"rate_limit_burst": {
+			Type:        framework.TypeInt,
+			Description: "Number of calls that may be made at once before rate_limit applies. Defaults to rate_limit rounded up.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Rate Limit Burst",
+			},
+		},
+		"max_in_flight": {
+			Type:        framework.TypeInt,
+			Description: "Maximum number of concurrent calls to Confluent Cloud. If not set or set to 0, concurrency is not limited.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Max In-Flight Calls",
+			},
+		},
+		"rate_limit_wait": {
+			Type:        framework.TypeDurationSecond,
+			Default:     int(defaultRateLimitWait.Seconds()),
+			Description: "How long a call waits for rate_limit and max_in_flight before it fails as rate limited locally",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Rate Limit Wait",
+			},
+		},
+		"verify_connection": {
+			Type:        framework.TypeBool,
+			Default:     true,
+			Description: "Verify that Confluent Cloud is reachable and the credentials are allowed to manage API keys before storing the configuration. Only checked when the URL or credentials change.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Verify Connection",
+			},
+		},
+		"rotation_period": {
+			Type:        framework.TypeDurationSecond,
+			Description: "How often the backend rotates its own Confluent Cloud API key. If not set or set to 0, the key is not rotated automatically.",
+			Required:    false,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Rotation Period",
+			},
+		},
+		"rotation_window": {
+			Type:        framework.TypeDurationSecond,
+			Description: "How long after the scheduled time a rotation may still be attempted. If the window passes without a successful rotation, it is skipped until the next period. If not set or set to 0, the window is unbounded.",
+			Required:    false,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Rotation Window",
+			},
+		},
+	}
+	pluginidentityutil.AddPluginIdentityTokenFields(fields)
+
+	return &framework.Path{
+		Pattern: "config(/" + framework.GenericNameRegex("connection") + ")?",
+		Fields:  fields,
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.ReadOperation: &framework.PathOperation{
 				Callback: b.pathConfigRead,
@@ -74,9 +389,34 @@ func pathConfig(b *ccloudBackend) *framework.Path {
 	}
 }
 
+// pathConfigList extends the Vault API with a `/config` list endpoint for the
+// named connections.
+func pathConfigList(b *ccloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/?$",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathConfigList,
+			},
+		},
+		HelpSynopsis:    pathConfigListHelpSynopsis,
+		HelpDescription: pathConfigListHelpDescription,
+	}
+}
+
+// pathConfigList lists the configured connections
+func (b *ccloudBackend) pathConfigList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	connections, err := listConnections(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(connections), nil
+}
+
 // pathConfigExistenceCheck verifies if the configuration exists.
 func (b *ccloudBackend) pathConfigExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
-	out, err := req.Storage.Get(ctx, req.Path)
+	out, err := req.Storage.Get(ctx, connectionStoragePath(connectionName(data)))
 	if err != nil {
 		return false, fmt.Errorf("existence check failed: %w", err)
 	}
@@ -85,24 +425,66 @@ func (b *ccloudBackend) pathConfigExistenceCheck(ctx context.Context, req *logic
 }
 
 // pathConfigRead reads the configuration and outputs non-sensitive information.
+// Secrets are never returned, only whether they are set.
 func (b *ccloudBackend) pathConfigRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	config, err := getConfig(ctx, req.Storage)
+	config, err := getConfig(ctx, req.Storage, connectionName(data))
 	if err != nil {
 		return nil, err
 	}
 
+	respData := map[string]interface{}{
+		"ccloud_api_key_id":         config.ApiKeyId,
+		"ccloud_api_key_secret_set": config.ApiKeySecret != "",
+		"url":                       config.URL,
+		"oauth_token_url":           config.OAuthTokenURL,
+		"oauth_client_id":           config.OAuthClientId,
+		"oauth_client_secret_set":   config.OAuthClientSecret != "",
+		"oauth_scopes":              config.OAuthScopes,
+		"identity_pool_id":          config.IdentityPoolId,
+		"sts_url":                   config.STSURL,
+		"ca_cert":                   config.CACert,
+		"proxy_url":                 config.ProxyURL,
+		"tls_server_name":           config.TLSServerName,
+		"request_timeout":           int64(config.RequestTimeout.Seconds()),
+		"max_idle_conns":            config.MaxIdleConns,
+		"max_retries":               config.MaxRetries,
+		"retry_budget":              int64(config.RetryBudget.Seconds()),
+		"rate_limit":                config.RateLimit,
+		"rate_limit_burst":          config.RateLimitBurst,
+		"max_in_flight":             config.MaxInFlight,
+		"rate_limit_wait":           int64(config.RateLimitWait.Seconds()),
+		"rotation_period":           int64(config.RotationPeriod.Seconds()),
+		"rotation_window":           int64(config.RotationWindow.Seconds()),
+	}
+	if !config.LastRotated.IsZero() {
+		respData["last_rotated"] = config.LastRotated.Format(time.RFC3339)
+	}
+	if !config.NextRotation.IsZero() {
+		respData["next_rotation"] = config.NextRotation.Format(time.RFC3339)
+	}
+	if config.LastRotationError != "" {
+		respData["last_rotation_error"] = config.LastRotationError
+	}
+	config.PopulatePluginIdentityTokenData(respData)
+
 	return &logical.Response{
-		Data: map[string]interface{}{
-			"ccloud_api_key_id":     config.ApiKeyId,
-			"ccloud_api_key_secret": config.ApiKeySecret,
-			"url":                   config.URL,
-		},
+		Data: respData,
 	}, nil
 }
 
 // pathConfigWrite updates the configuration for the backend
 func (b *ccloudBackend) pathConfigWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	config, err := getConfig(ctx, req.Storage)
+	connection := connectionName(data)
+	if slices.Contains(reservedConnectionNames, connection) {
+		return logical.ErrorResponse("connection name %q is reserved", connection), nil
+	}
+
+	// a rotation of the root credential must not be overwritten with the
+	// credential it deleted
+	b.rotationLock.Lock()
+	defer b.rotationLock.Unlock()
+
+	config, err := getConfig(ctx, req.Storage, connection)
 	if err != nil {
 		return nil, err
 	}
@@ -116,67 +498,216 @@ func (b *ccloudBackend) pathConfigWrite(ctx context.Context, req *logical.Reques
 		config = new(ccloudConfig)
 	}
 
+	previous := *config
+
+	if tokenURL, ok := data.GetOk("oauth_token_url"); ok {
+		config.OAuthTokenURL = tokenURL.(string)
+	}
+	if clientId, ok := data.GetOk("oauth_client_id"); ok {
+		config.OAuthClientId = clientId.(string)
+	}
+	if clientSecret, ok := data.GetOk("oauth_client_secret"); ok {
+		config.OAuthClientSecret = clientSecret.(string)
+	}
+	if scopes, ok := data.GetOk("oauth_scopes"); ok {
+		config.OAuthScopes = scopes.([]string)
+	}
+	if identityPoolId, ok := data.GetOk("identity_pool_id"); ok {
+		config.IdentityPoolId = identityPoolId.(string)
+	}
+	if stsURL, ok := data.GetOk("sts_url"); ok {
+		config.STSURL = stsURL.(string)
+	}
+	if err := config.ParsePluginIdentityTokenFields(data); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	apiKeyRequired := createOperation && !config.usesOAuth() && !config.usesWorkloadIdentity()
+
 	if keyId, ok := data.GetOk("ccloud_api_key_id"); ok {
 		config.ApiKeyId = keyId.(string)
-	} else if !ok && createOperation {
+	} else if !ok && apiKeyRequired {
 		return nil, fmt.Errorf("missing ccloud_api_key_id in configuration")
 	}
 	if secret, ok := data.GetOk("ccloud_api_key_secret"); ok {
 		config.ApiKeySecret = secret.(string)
-	} else if !ok && createOperation {
+	} else if !ok && apiKeyRequired {
 		return nil, fmt.Errorf("missing ccloud_api_key_secret in configuration")
 	}
 
+	if err := config.validateAuth(); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
 	if url, ok := data.GetOk("url"); ok {
 		config.URL = url.(string)
 	} else if !ok && createOperation {
 		config.URL = data.GetDefaultOrZero("url").(string)
 	}
 
-	entry, err := logical.StorageEntryJSON(configStoragePath, config)
-	if err != nil {
-		return nil, err
+	if caCert, ok := data.GetOk("ca_cert"); ok {
+		config.CACert = caCert.(string)
+	}
+	if proxyURL, ok := data.GetOk("proxy_url"); ok {
+		config.ProxyURL = proxyURL.(string)
+	}
+	if serverName, ok := data.GetOk("tls_server_name"); ok {
+		config.TLSServerName = serverName.(string)
+	}
+	if requestTimeout, ok := data.GetOk("request_timeout"); ok {
+		config.RequestTimeout = time.Duration(requestTimeout.(int)) * time.Second
+	}
+	if maxIdleConns, ok := data.GetOk("max_idle_conns"); ok {
+		config.MaxIdleConns = maxIdleConns.(int)
+	}
+	if _, err := newHTTPClient(config); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if maxRetries, ok := data.GetOk("max_retries"); ok {
+		config.MaxRetries = maxRetries.(int)
+	}
+	if retryBudget, ok := data.GetOk("retry_budget"); ok {
+		config.RetryBudget = time.Duration(retryBudget.(int)) * time.Second
+	}
+	if config.MaxRetries < 0 || config.RetryBudget < 0 {
+		return logical.ErrorResponse("max_retries and retry_budget cannot be negative"), nil
+	}
+
+	if rateLimit, ok := data.GetOk("rate_limit"); ok {
+		config.RateLimit = rateLimit.(float64)
+	}
+	if burst, ok := data.GetOk("rate_limit_burst"); ok {
+		config.RateLimitBurst = burst.(int)
+	}
+	if maxInFlight, ok := data.GetOk("max_in_flight"); ok {
+		config.MaxInFlight = maxInFlight.(int)
+	}
+	if wait, ok := data.GetOk("rate_limit_wait"); ok {
+		config.RateLimitWait = time.Duration(wait.(int)) * time.Second
+	}
+	if config.RateLimit < 0 || config.RateLimitBurst < 0 || config.MaxInFlight < 0 || config.RateLimitWait < 0 {
+		return logical.ErrorResponse("rate_limit, rate_limit_burst, max_in_flight and rate_limit_wait cannot be negative"), nil
 	}
 
-	if err := req.Storage.Put(ctx, entry); err != nil {
+	if rotationPeriod, ok := data.GetOk("rotation_period"); ok {
+		config.RotationPeriod = time.Duration(rotationPeriod.(int)) * time.Second
+		config.scheduleRotation(time.Now())
+	}
+
+	if rotationWindow, ok := data.GetOk("rotation_window"); ok {
+		config.RotationWindow = time.Duration(rotationWindow.(int)) * time.Second
+	}
+
+	if config.RotationPeriod < 0 || config.RotationWindow < 0 {
+		return logical.ErrorResponse("rotation_period and rotation_window cannot be negative"), nil
+	}
+
+	verifyConnection := true
+	if verify, ok := data.GetOk("verify_connection"); ok {
+		verifyConnection = verify.(bool)
+	}
+
+	var verifiedClient *ccloudAPIKeyClient
+	if verifyConnection && (createOperation || config.connectionChanged(&previous)) {
+		client, err := b.buildClient(config)
+		if err != nil {
+			return logical.ErrorResponse("error verifying connection: %s", err), nil
+		}
+		if err := client.VerifyConnection(ctx); err != nil {
+			return logical.ErrorResponse("error verifying connection: %s", err), nil
+		}
+		verifiedClient = client
+	}
+
+	if err := setConfig(ctx, req.Storage, connection, config); err != nil {
 		return nil, err
 	}
 
-	// reset the client so the next invocation will pick up the new configuration
-	b.reset()
+	// reset the client so the next invocation will pick up the new
+	// configuration, reusing the client that was just verified
+	b.resetClient(connection)
+	if verifiedClient != nil {
+		b.setClient(connection, verifiedClient)
+	}
 
 	return nil, nil
 }
 
 // pathConfigDelete removes the configuration for the backend
 func (b *ccloudBackend) pathConfigDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	err := req.Storage.Delete(ctx, configStoragePath)
+	connection := connectionName(data)
+
+	b.rotationLock.Lock()
+	defer b.rotationLock.Unlock()
+
+	err := req.Storage.Delete(ctx, connectionStoragePath(connection))
 
 	if err == nil {
-		b.reset()
+		b.resetClient(connection)
 	}
 
 	return nil, err
 }
 
-func getConfig(ctx context.Context, s logical.Storage) (*ccloudConfig, error) {
-	entry, err := s.Get(ctx, configStoragePath)
+// setConfig adds the configuration of a connection to the Vault storage API
+func setConfig(ctx context.Context, s logical.Storage, connection string, config *ccloudConfig) error {
+	config.Version = configStorageVersion
+	entry, err := logical.StorageEntryJSON(connectionStoragePath(connection), config)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// getConfig gets the configuration of a connection from the Vault storage API.
+// A connection that has not been configured yields an empty configuration.
+func getConfig(ctx context.Context, s logical.Storage, connection string) (*ccloudConfig, error) {
+	entry, err := s.Get(ctx, connectionStoragePath(connection))
 	if err != nil {
 		return nil, err
 	}
 
-	config := new(ccloudConfig)
+	config := &ccloudConfig{
+		MaxRetries:  defaultMaxRetries,
+		RetryBudget: defaultRetryBudget,
+
+		RateLimitWait: defaultRateLimitWait,
+	}
 
 	if entry != nil {
 		if err := entry.DecodeJSON(&config); err != nil {
 			return nil, fmt.Errorf("error reading root configuration: %w", err)
 		}
+		if config.Version > configStorageVersion {
+			return nil, fmt.Errorf("configuration of connection %q was written by a newer version of the plugin (storage version %d)", normalizeConnection(connection), config.Version)
+		}
 	}
 
 	// return the config, we are done
 	return config, nil
 }
 
+// listConnections returns the names of all configured connections, including
+// the default connection if it is configured.
+func listConnections(ctx context.Context, s logical.Storage) ([]string, error) {
+	connections, err := s.List(ctx, configStoragePath+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := s.Get(ctx, configStoragePath)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		connections = append([]string{defaultConnectionName}, connections...)
+	}
+
+	return connections, nil
+}
+
 // pathConfigHelpSynopsis summarizes the help text for the configuration
 const pathConfigHelpSynopsis = `Configure the CCloud backend.`
 
@@ -187,4 +718,51 @@ Cluster tokens using the Confluent Cloud API.
 
 You must provide a Confluent Cloud API key with permission to manage Cluster
 API tokens before using this secrets backend.
+
+Alternatively, the backend can authenticate with an OAuth client registered
+with an external identity provider. Set "oauth_token_url", "oauth_client_id",
+"oauth_client_secret" and "identity_pool_id" instead of the API key; the
+backend then exchanges client credentials tokens for Confluent Cloud access
+tokens and refreshes them before they expire.
+
+To avoid storing any static secret, set "identity_token_audience" and
+"identity_pool_id" instead. The backend then exchanges plugin identity tokens
+signed by Vault for Confluent Cloud access tokens. This requires Vault
+Enterprise and an identity provider in Confluent Cloud that trusts Vault's
+plugin identity token issuer.
+
+Additional connections, for example to other Confluent Cloud organizations,
+can be configured at "config/<connection>" and selected with the "connection"
+field of a role. The connection at "config" is named "default".
+
+Unless "verify_connection" is set to false, the backend checks that it can
+reach Confluent Cloud and manage API keys with the given credentials before
+storing them.
+
+Requests are sent through "proxy_url", or the proxy configured in the
+environment of the Vault server, and may trust additional CAs given in
+"ca_cert". Each request times out after "request_timeout", 30 seconds by
+default.
+
+Calls that fail with a server error, a rate limit or without a response are
+retried up to "max_retries" times, backing off exponentially or as requested
+by Confluent Cloud, for at most "retry_budget". A failed key creation is only
+retried after checking that no key was created.
+
+To avoid getting throttled by Confluent Cloud, calls of a connection can be
+limited to "rate_limit" per second and "max_in_flight" at a time. Calls over
+the limits queue for up to "rate_limit_wait" and then fail as rate limited
+locally.
+
+If "rotation_period" is set, the backend periodically replaces its own API key
+with a new one for the same owner. Reading the configuration reports when the
+key was last rotated, when the next rotation is due and the error of the last
+failed rotation, if any.
+`
+
+const pathConfigListHelpSynopsis = `List the configured Confluent Cloud connections.`
+
+const pathConfigListHelpDescription = `
+Connections are listed by name. The connection configured at "config" is
+listed as "default".
 `
@@ -0,0 +1,207 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+var errNoRootCredential = errors.New("no root credential configured")
+
+const (
+	rootKeyDisplayName = "vault-root-credential"
+	rootKeyDescription = "Root credential of the Vault Confluent Cloud secrets engine"
+)
+
+// pathConfigRotateRoot extends the Vault API with a `/config/rotate-root`
+// endpoint that replaces the engine's own Cloud API key.
+func pathConfigRotateRoot(b *ccloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config(/" + framework.GenericNameRegex("connection") + ")?/rotate-root",
+		Fields: map[string]*framework.FieldSchema{
+			"connection": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the connection. If not set, the default connection is used.",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback:                    b.pathConfigRotateRootUpdate,
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: true,
+			},
+		},
+		HelpSynopsis:    pathConfigRotateRootHelpSynopsis,
+		HelpDescription: pathConfigRotateRootHelpDescription,
+	}
+}
+
+// pathConfigRotateRootUpdate rotates the root credential and returns the new
+// key ID. The new secret is never returned.
+func (b *ccloudBackend) pathConfigRotateRootUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	connection := connectionName(data)
+	keyId, err := b.rotateRootCredential(ctx, req.Storage, connection)
+	if errors.Is(err, errNoRootCredential) {
+		config, err := getConfig(ctx, req.Storage, connection)
+		if err != nil {
+			return nil, err
+		}
+		if config.usesOAuth() || config.usesWorkloadIdentity() {
+			return logical.ErrorResponse("connection %q authenticates without a root API key; nothing to rotate", normalizeConnection(connection)), nil
+		}
+		return logical.ErrorResponse(errNoRootCredential.Error()), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"ccloud_api_key_id": keyId,
+		},
+	}, nil
+}
+
+// rotateRootCredential rotates the root credential and records the outcome
+// in the configuration, so failures show up in the config status.
+func (b *ccloudBackend) rotateRootCredential(ctx context.Context, s logical.Storage, connection string) (string, error) {
+	b.rotationLock.Lock()
+	defer b.rotationLock.Unlock()
+
+	return b.rotateRootCredentialLocked(ctx, s, connection)
+}
+
+// rotateRootIfDue rotates the root credential if its rotation period has
+// elapsed. A rotation that has not succeeded within the rotation window is
+// skipped until the next period.
+func (b *ccloudBackend) rotateRootIfDue(ctx context.Context, s logical.Storage, connection string) error {
+	b.rotationLock.Lock()
+	defer b.rotationLock.Unlock()
+
+	config, err := getConfig(ctx, s, connection)
+	if err != nil {
+		return err
+	}
+
+	if config.ApiKeyId == "" || config.RotationPeriod <= 0 || config.NextRotation.IsZero() {
+		return nil
+	}
+
+	now := time.Now()
+	if now.Before(config.NextRotation) {
+		return nil
+	}
+
+	if config.RotationWindow > 0 && now.After(config.NextRotation.Add(config.RotationWindow)) {
+		missed := config.NextRotation
+		for !config.NextRotation.After(now) {
+			config.NextRotation = config.NextRotation.Add(config.RotationPeriod)
+		}
+		config.LastRotationError = fmt.Sprintf("rotation window for rotation scheduled at %s elapsed", missed.Format(time.RFC3339))
+		b.Logger().Warn("skipped root credential rotation", "connection", connection, "scheduled", missed, "next_rotation", config.NextRotation)
+
+		return setConfig(ctx, s, connection, config)
+	}
+
+	_, err = b.rotateRootCredentialLocked(ctx, s, connection)
+	return err
+}
+
+// rotateRootCredentialLocked performs the rotation. The caller must hold
+// rotationLock.
+func (b *ccloudBackend) rotateRootCredentialLocked(ctx context.Context, s logical.Storage, connection string) (string, error) {
+	keyId, err := b.doRotateRootCredential(ctx, s, connection)
+	if err != nil && !errors.Is(err, errNoRootCredential) {
+		b.Logger().Error("failed to rotate root credential", "connection", connection, "error", err)
+		if saveErr := saveRootRotationError(ctx, s, connection, err); saveErr != nil {
+			b.Logger().Error("failed to save root rotation status", "connection", connection, "error", saveErr)
+		}
+	}
+
+	return keyId, err
+}
+
+// doRotateRootCredential creates a new Cloud API key for the owner of the
+// configured key, stores it, and only then deletes the previous key. If the
+// new key cannot be stored, it is deleted again and the old key stays active.
+func (b *ccloudBackend) doRotateRootCredential(ctx context.Context, s logical.Storage, connection string) (string, error) {
+	config, err := getConfig(ctx, s, connection)
+	if err != nil {
+		return "", err
+	}
+
+	if config.ApiKeyId == "" {
+		return "", errNoRootCredential
+	}
+
+	client, err := b.getClient(ctx, s, connection)
+	if err != nil {
+		return "", fmt.Errorf("error getting client: %w", err)
+	}
+
+	owner, ownerEnv, err := client.GetApiKeyOwner(ctx, config.ApiKeyId)
+	if err != nil {
+		return "", err
+	}
+
+	keyId, secret, err := client.CreateApiKey(ctx, owner, ownerEnv, "", "", rootKeyDisplayName, rootKeyDescription)
+	if err != nil {
+		return "", err
+	}
+
+	oldKeyId := config.ApiKeyId
+	config.ApiKeyId = keyId
+	config.ApiKeySecret = secret
+	config.LastRotated = time.Now()
+	config.LastRotationError = ""
+	config.scheduleRotation(config.LastRotated)
+
+	if err := setConfig(ctx, s, connection, config); err != nil {
+		if delErr := client.DeleteApiKey(ctx, keyId); delErr != nil {
+			b.Logger().Error("failed to delete unused root credential", "key_id", keyId, "error", delErr)
+		}
+		return "", fmt.Errorf("error storing rotated root credential: %w", err)
+	}
+
+	// reset the client so the next invocation will pick up the new key
+	b.resetClient(connection)
+
+	// the old client is still authenticated with the old key, which keeps
+	// working until it is deleted and avoids waiting for the new key to
+	// propagate
+	if err := client.DeleteApiKey(ctx, oldKeyId); err != nil {
+		return "", fmt.Errorf("root credential rotated to %s but failed to delete previous key %s: %w", keyId, oldKeyId, err)
+	}
+
+	b.Logger().Info("rotated root credential", "connection", connection, "key_id", keyId, "previous_key_id", oldKeyId)
+
+	return keyId, nil
+}
+
+// saveRootRotationError records a failed rotation in the configuration
+func saveRootRotationError(ctx context.Context, s logical.Storage, connection string, rotationErr error) error {
+	config, err := getConfig(ctx, s, connection)
+	if err != nil {
+		return err
+	}
+
+	config.LastRotationError = rotationErr.Error()
+
+	return setConfig(ctx, s, connection, config)
+}
+
+const pathConfigRotateRootHelpSynopsis = `Rotate the Confluent Cloud API key used by the backend.`
+
+const pathConfigRotateRootHelpDescription = `
+Creates a new Cloud API key for the owner of the currently configured key,
+stores it as the backend's credential and deletes the previous key. After
+rotation the secret is only known to Vault.
+
+Use "config/<connection>/rotate-root" to rotate the key of a named connection.
+Rotation can also be scheduled with the "rotation_period" and
+"rotation_window" fields of the "config" endpoint.
+`
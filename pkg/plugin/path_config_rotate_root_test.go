@@ -0,0 +1,208 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateRootReplacesRootCredential(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/rotate-root",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+
+	newKeyId := resp.Data["ccloud_api_key_id"].(string)
+	assert.NotEqual(t, fakeRootKeyId, newKeyId)
+	assert.NotContains(t, resp.Data, "ccloud_api_key_secret")
+
+	assert.False(t, fake.hasKey(fakeRootKeyId), "old root key should be deleted")
+	assert.True(t, fake.hasKey(newKeyId), "new root key should exist")
+
+	config, err := getConfig(context.Background(), s, defaultConnectionName)
+	require.NoError(t, err)
+	assert.Equal(t, newKeyId, config.ApiKeyId)
+	assert.NotEqual(t, fakeRootKeySecret, config.ApiKeySecret)
+
+	// the cached client must have been replaced, so a second rotation
+	// authenticates with the new key
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/rotate-root",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.False(t, fake.hasKey(newKeyId))
+	assert.Equal(t, 1, fake.keyCount())
+}
+
+func TestRotateRootReturnsErrorWithoutConfig(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/rotate-root",
+		Storage:   s,
+	})
+
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+	assert.EqualError(t, resp.Error(), "no root credential configured")
+}
+
+func TestRotateRootRefusesConnectionsWithoutRootKey(t *testing.T) {
+	fake := newFakeCCloud(t)
+	idp := newTestIdentityProvider(t)
+	b, s := getTestBackend(t)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "config/oauth",
+		Data: map[string]interface{}{
+			"url":                 fake.URL,
+			"oauth_token_url":     idp.URL,
+			"oauth_client_id":     testOAuthClientId,
+			"oauth_client_secret": testOAuthClientSecret,
+			"identity_pool_id":    fakeIdentityPoolId,
+		},
+		Storage: s,
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/oauth/rotate-root",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+	assert.EqualError(t, resp.Error(), `connection "oauth" authenticates without a root API key; nothing to rotate`)
+}
+
+func TestPeriodicFuncRotatesRootCredentialWhenDue(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	_, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      configStoragePath,
+		Data:      map[string]interface{}{"rotation_period": "24h"},
+		Storage:   s,
+	})
+	require.NoError(t, err)
+
+	// not due yet
+	require.NoError(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+	assert.True(t, fake.hasKey(fakeRootKeyId))
+
+	config, err := getConfig(context.Background(), s, defaultConnectionName)
+	require.NoError(t, err)
+	config.NextRotation = time.Now().Add(-time.Minute)
+	require.NoError(t, setConfig(context.Background(), s, defaultConnectionName, config))
+
+	require.NoError(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+	assert.False(t, fake.hasKey(fakeRootKeyId))
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      configStoragePath,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, fakeRootKeyId, resp.Data["ccloud_api_key_id"])
+	assert.Equal(t, int64(24*60*60), resp.Data["rotation_period"])
+	assert.NotEmpty(t, resp.Data["last_rotated"])
+	assert.NotContains(t, resp.Data, "last_rotation_error")
+
+	nextRotation, err := time.Parse(time.RFC3339, resp.Data["next_rotation"].(string))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), nextRotation, time.Minute)
+}
+
+func TestPeriodicFuncKeepsRootCredentialWhenRotationFails(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	config, err := getConfig(context.Background(), s, defaultConnectionName)
+	require.NoError(t, err)
+	config.RotationPeriod = time.Hour
+	config.NextRotation = time.Now().Add(-time.Minute)
+	config.ApiKeySecret = "wrong-secret"
+	require.NoError(t, setConfig(context.Background(), s, defaultConnectionName, config))
+	b.resetClient(defaultConnectionName)
+
+	err = b.periodicFunc(context.Background(), &logical.Request{Storage: s})
+	require.Error(t, err)
+
+	config, err = getConfig(context.Background(), s, defaultConnectionName)
+	require.NoError(t, err)
+	assert.Equal(t, fakeRootKeyId, config.ApiKeyId)
+	assert.Equal(t, "wrong-secret", config.ApiKeySecret)
+	assert.True(t, config.LastRotated.IsZero())
+	assert.NotEmpty(t, config.LastRotationError)
+	assert.Equal(t, 1, fake.keyCount())
+}
+
+func TestPeriodicFuncSkipsRotationAfterWindow(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	config, err := getConfig(context.Background(), s, defaultConnectionName)
+	require.NoError(t, err)
+	config.RotationPeriod = time.Hour
+	config.RotationWindow = time.Minute
+	config.NextRotation = time.Now().Add(-90 * time.Minute)
+	require.NoError(t, setConfig(context.Background(), s, defaultConnectionName, config))
+
+	require.NoError(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+	assert.True(t, fake.hasKey(fakeRootKeyId))
+
+	config, err = getConfig(context.Background(), s, defaultConnectionName)
+	require.NoError(t, err)
+	assert.True(t, config.NextRotation.After(time.Now()))
+	assert.Contains(t, config.LastRotationError, "rotation window")
+}
+
+func TestConfigWriteWaitsForRootRotation(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	// a rotation is in progress
+	b.rotationLock.Lock()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      configStoragePath,
+			Data:      map[string]interface{}{"max_retries": 1},
+			Storage:   s,
+		})
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("config write did not wait for the rotation")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	b.rotationLock.Unlock()
+	require.NoError(t, <-done)
+}
@@ -2,8 +2,10 @@ package plugin
 
 import (
 	"context"
+	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -24,6 +26,27 @@ func TestPathConfigExistenceCheck(t *testing.T) {
 	assert.NotNil(t, configExists.ExistenceCheck)
 }
 
+func TestPathConfigExistenceCheckFindsDefaultConnection(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	for path, expected := range map[string]bool{
+		configStoragePath: true,
+		configStoragePath + "/" + defaultConnectionName: true,
+		configStoragePath + "/other":                    false,
+	} {
+		checkFound, exists, err := b.HandleExistenceCheck(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      path,
+			Storage:   s,
+		})
+		require.NoError(t, err, path)
+		require.True(t, checkFound, path)
+		assert.Equal(t, expected, exists, path)
+	}
+}
+
 func TestConfigDeleteReturnsNoErrors(t *testing.T) {
 	logicalBackend, logicalStorage := getTestBackend(t)
 	resp, err := logicalBackend.HandleRequest(context.Background(), &logical.Request{
@@ -43,6 +66,7 @@ func TestConfigCreateReturnsNoErrors(t *testing.T) {
 		"ccloud_api_key_id":     apiKeyId,
 		"ccloud_api_key_secret": apiKeySecret,
 		"url":                   url,
+		"verify_connection":     false,
 	}
 
 	resp, err := logicalBackend.HandleRequest(context.Background(), &logical.Request{
@@ -62,6 +86,7 @@ func TestConfigUpdateReturnsNoErrors(t *testing.T) {
 		"ccloud_api_key_id":     apiKeyId,
 		"ccloud_api_key_secret": apiKeySecret,
 		"url":                   "http://ccloud:19090",
+		"verify_connection":     false,
 	}
 	resp, err := logicalBackend.HandleRequest(context.Background(), &logical.Request{
 		Operation: logical.UpdateOperation,
@@ -92,3 +117,199 @@ func TestConfigReadReturnsNoErrors(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, resp)
 }
+
+func TestConfigNamedConnections(t *testing.T) {
+	logicalBackend, logicalStorage := getTestBackend(t)
+
+	for _, path := range []string{"config", "config/org-a", "config/org-b"} {
+		resp, err := logicalBackend.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      path,
+			Data: map[string]interface{}{
+				"ccloud_api_key_id":     path + "-key",
+				"ccloud_api_key_secret": apiKeySecret,
+				"url":                   url,
+				"verify_connection":     false,
+			},
+			Storage: logicalStorage,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	}
+
+	resp, err := logicalBackend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "config/",
+		Storage:   logicalStorage,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{defaultConnectionName, "org-a", "org-b"}, resp.Data["keys"])
+
+	resp, err = logicalBackend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/org-a",
+		Storage:   logicalStorage,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "config/org-a-key", resp.Data["ccloud_api_key_id"])
+
+	resp, err = logicalBackend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/default",
+		Storage:   logicalStorage,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "config-key", resp.Data["ccloud_api_key_id"])
+
+	_, err = logicalBackend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "config/org-a",
+		Storage:   logicalStorage,
+	})
+	require.NoError(t, err)
+
+	connections, err := listConnections(context.Background(), logicalStorage)
+	require.NoError(t, err)
+	assert.Equal(t, []string{defaultConnectionName, "org-b"}, connections)
+}
+
+func TestConfigWriteRejectsReservedConnectionNames(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	// config/rotate-root routes to the rotation, so the connection is only
+	// reachable through the handler itself
+	path := pathConfig(b)
+	resp, err := b.pathConfigWrite(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "config/rotate-root",
+		Storage:   s,
+	}, &framework.FieldData{
+		Raw: map[string]interface{}{
+			"connection":            "rotate-root",
+			"ccloud_api_key_id":     apiKeyId,
+			"ccloud_api_key_secret": apiKeySecret,
+			"url":                   url,
+			"verify_connection":     false,
+		},
+		Schema: path.Fields,
+	})
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), `connection name "rotate-root" is reserved`)
+
+	connections, err := listConnections(context.Background(), s)
+	require.NoError(t, err)
+	assert.Empty(t, connections)
+}
+
+func TestConfigWriteVerifiesConnection(t *testing.T) {
+	fake := newFakeCCloud(t)
+	logicalBackend, logicalStorage := getTestBackend(t)
+
+	writeConfig := func(operation logical.Operation, data map[string]interface{}) *logical.Response {
+		resp, err := logicalBackend.HandleRequest(context.Background(), &logical.Request{
+			Operation: operation,
+			Path:      configStoragePath,
+			Data:      data,
+			Storage:   logicalStorage,
+		})
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := writeConfig(logical.CreateOperation, map[string]interface{}{
+		"ccloud_api_key_id":     fakeRootKeyId,
+		"ccloud_api_key_secret": "wrong",
+		"url":                   fake.URL,
+	})
+	require.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "invalid credentials")
+
+	config, err := getConfig(context.Background(), logicalStorage, defaultConnectionName)
+	require.NoError(t, err)
+	assert.Empty(t, config.ApiKeyId, "config must not be stored when verification fails")
+
+	resp = writeConfig(logical.CreateOperation, map[string]interface{}{
+		"ccloud_api_key_id":     fakeRootKeyId,
+		"ccloud_api_key_secret": fakeRootKeySecret,
+		"url":                   "http://127.0.0.1:1",
+	})
+	require.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "unable to reach")
+
+	fake.setForbidden(true)
+	resp = writeConfig(logical.CreateOperation, map[string]interface{}{
+		"ccloud_api_key_id":     fakeRootKeyId,
+		"ccloud_api_key_secret": fakeRootKeySecret,
+		"url":                   fake.URL,
+	})
+	require.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "insufficient permissions")
+
+	fake.setForbidden(false)
+	resp = writeConfig(logical.CreateOperation, map[string]interface{}{
+		"ccloud_api_key_id":     fakeRootKeyId,
+		"ccloud_api_key_secret": fakeRootKeySecret,
+		"url":                   fake.URL,
+	})
+	require.Nil(t, resp)
+
+	// settings that do not affect the connection are not verified again
+	fake.setForbidden(true)
+	resp = writeConfig(logical.UpdateOperation, map[string]interface{}{
+		"rotation_period": "24h",
+	})
+	require.Nil(t, resp)
+
+	resp = writeConfig(logical.UpdateOperation, map[string]interface{}{
+		"ccloud_api_key_secret": "changed",
+	})
+	require.True(t, resp.IsError())
+
+	resp = writeConfig(logical.UpdateOperation, map[string]interface{}{
+		"ccloud_api_key_secret": "changed",
+		"verify_connection":     false,
+	})
+	require.Nil(t, resp)
+}
+
+func TestConfigReadDoesNotReturnSecrets(t *testing.T) {
+	fake := newFakeCCloud(t)
+	idp := newTestIdentityProvider(t)
+	logicalBackend, logicalStorage := getTestBackend(t)
+	fake.configure(t, logicalBackend, logicalStorage)
+
+	resp, err := logicalBackend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "config/oauth",
+		Data: map[string]interface{}{
+			"url":                 fake.URL,
+			"oauth_token_url":     idp.URL,
+			"oauth_client_id":     testOAuthClientId,
+			"oauth_client_secret": testOAuthClientSecret,
+			"identity_pool_id":    fakeIdentityPoolId,
+		},
+		Storage: logicalStorage,
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	resp, err = logicalBackend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      configStoragePath,
+		Storage:   logicalStorage,
+	})
+	require.NoError(t, err)
+	requireNoSecrets(t, resp, fakeRootKeySecret)
+	assert.Equal(t, true, resp.Data["ccloud_api_key_secret_set"])
+
+	resp, err = logicalBackend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/oauth",
+		Storage:   logicalStorage,
+	})
+	require.NoError(t, err)
+	requireNoSecrets(t, resp, testOAuthClientSecret)
+	assert.Equal(t, true, resp.Data["oauth_client_secret_set"])
+	assert.Equal(t, false, resp.Data["ccloud_api_key_secret_set"])
+}
@@ -2,9 +2,14 @@ package plugin
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"time"
+
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -47,18 +52,23 @@ func (b *ccloudBackend) pathCredentialsRead(ctx context.Context, req *logical.Re
 		return nil, errors.New("error retrieving role: role is nil")
 	}
 
+	var resp *logical.Response
 	if roleEntry.MultiUseKey == false {
-		return b.createCredential(ctx, req, roleName, roleEntry)
+		resp, err = b.createCredential(ctx, req, roleName, roleEntry)
 	} else {
-		return b.readOrCreateCredential(ctx, req, roleName, roleEntry)
+		resp, err = b.readOrCreateCredential(ctx, req, roleName)
 	}
+
+	// calls refused or throttled by Confluent Cloud are reported with their
+	// own status instead of a generic internal error
+	return resp, codedError(err)
 }
 
 // createCredential creates a new Cluster API Key to store into the Vault
 // backend, generates a response with the secrets information, and checks the
 // TTL and MaxTTL attributes.
 func (b *ccloudBackend) createCredential(ctx context.Context, req *logical.Request, roleName string, role *apikeyRoleEntry) (*logical.Response, error) {
-	token, err := b.createClusterKey(ctx, req, role)
+	token, err := b.createClusterKey(ctx, req, roleName, role)
 
 	if err != nil {
 		return nil, err
@@ -69,18 +79,33 @@ func (b *ccloudBackend) createCredential(ctx context.Context, req *logical.Reque
 	// store it in internal data!
 	resp := b.Secret(ccloudClusterApiKeyType).Response(
 		// Data
-		map[string]interface{}{
-			"key_id":           token.KeyId,
-			"secret":           token.Secret,
-			"sasl.jaas.config": "org.apache.kafka.common.security.plain.PlainLoginModule required username='" + token.KeyId + "' password='" + token.Secret + "';",
-		},
+		credentialData(role.keyKind(), token.KeyId, token.Secret),
 		// Internal
 		map[string]interface{}{
-			"key_id": token.KeyId,
-			"role":   roleName,
+			"key_id":     token.KeyId,
+			"key_kind":   role.keyKind(),
+			"role":       roleName,
+			"connection": normalizeConnection(role.Connection),
 		},
 	)
 
+	if token.ServiceAccountId != "" {
+		resp.Data["service_account_id"] = token.ServiceAccountId
+		resp.Secret.InternalData["service_account_id"] = token.ServiceAccountId
+	}
+
+	if len(token.RoleBindingIds) > 0 {
+		resp.Secret.InternalData["role_binding_ids"] = token.RoleBindingIds
+	}
+
+	if token.ACLs != nil {
+		acls, err := token.ACLs.toInternalData()
+		if err != nil {
+			return nil, err
+		}
+		resp.Secret.InternalData["kafka_acls"] = acls
+	}
+
 	if role.TTL > 0 {
 		resp.Secret.TTL = role.TTL
 	}
@@ -90,10 +115,22 @@ func (b *ccloudBackend) createCredential(ctx context.Context, req *logical.Reque
 	}
 
 	if role.MultiUseKey == true {
-		role.CCKeyId = token.KeyId
-		role.CCKeySecret = token.Secret
-		role.UsageCount = 1
-		setRole(ctx, req.Storage, roleName, role)
+		leaseRef, err := newLeaseRef()
+		if err != nil {
+			return nil, errors.Join(err, b.rollbackClusterKey(ctx, req, role, token))
+		}
+
+		role.SharedKeys = append(role.SharedKeys, sharedKeyState{
+			KeyId:     token.KeyId,
+			Secret:    token.Secret,
+			Leases:    []string{leaseRef},
+			CreatedAt: time.Now(),
+		})
+		if err := setRole(ctx, req.Storage, roleName, role); err != nil {
+			// an untracked shared key would never be deleted
+			return nil, errors.Join(fmt.Errorf("error storing shared key: %w", err), b.rollbackClusterKey(ctx, req, role, token))
+		}
+		resp.Secret.InternalData["lease_ref"] = leaseRef
 	}
 
 	return resp, nil
@@ -103,7 +140,7 @@ func (b *ccloudBackend) createCredential(ctx context.Context, req *logical.Reque
 // backend, generates a response with the secrets information, and checks the
 // TTL and MaxTTL attributes.
 func (b *ccloudBackend) removeCredential(ctx context.Context, req *logical.Request, keyId string) (*logical.Response, error) {
-	client, err := b.getClient(ctx, req.Storage)
+	client, err := b.getClient(ctx, req.Storage, defaultConnectionName)
 
 	deleteToken(ctx, client, keyId)
 
@@ -112,65 +149,262 @@ func (b *ccloudBackend) removeCredential(ctx context.Context, req *logical.Reque
 
 // readOrCreateCredential reads an existing Cluster API key or creates it if it doesn't exist
 // backend, generates a response with the secrets information, and checks the
-// TTL and MaxTTL attributes.
-func (b *ccloudBackend) readOrCreateCredential(ctx context.Context, req *logical.Request, roleName string, role *apikeyRoleEntry) (*logical.Response, error) {
-	// first use = usage count 0 means the key has not been created yet
-	if role.UsageCount == 0 {
+// TTL and MaxTTL attributes. A shared key older than the role's
+// shared_key_max_age is no longer handed out; a new key is created instead.
+// The role is locked, so concurrent requests share a single new key.
+func (b *ccloudBackend) readOrCreateCredential(ctx context.Context, req *logical.Request, roleName string) (*logical.Response, error) {
+	lock := locksutil.LockForKey(b.roleLocks, roleName)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// the role may have changed before the lock was taken
+	role, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving role: %w", err)
+	}
+	if role == nil {
+		return nil, errors.New("error retrieving role: role is nil")
+	}
+
+	key := role.currentSharedKey(time.Now())
+	if key == nil {
+		if previous := role.newestSharedKey(); previous != nil {
+			b.Logger().Info("replacing shared key that reached its max age", "role", roleName, "key_id", previous.KeyId)
+		}
 		return b.createCredential(ctx, req, roleName, role)
 	}
 
-	// usage count > 0, we return the existing key
-	role.UsageCount++
-	setRole(ctx, req.Storage, roleName, role)
+	// the key is in use, we return it
+	leaseRef, err := newLeaseRef()
+	if err != nil {
+		return nil, err
+	}
+	key.Leases = append(key.Leases, leaseRef)
+	if err := setRole(ctx, req.Storage, roleName, role); err != nil {
+		return nil, fmt.Errorf("error storing shared key usage: %w", err)
+	}
 
 	return b.Secret(ccloudClusterApiKeyType).Response(
 		// Data
-		map[string]interface{}{
-			"key_id":           role.CCKeyId,
-			"secret":           role.CCKeySecret,
-			"sasl.jaas.config": "org.apache.kafka.common.security.plain.PlainLoginModule required username='" + role.CCKeyId + "' password='" + role.CCKeySecret + "';",
-		},
+		credentialData(role.keyKind(), key.KeyId, key.Secret),
 		// Internal
 		map[string]interface{}{
-			"key_id": role.CCKeyId,
-			"role":   roleName,
+			"key_id":     key.KeyId,
+			"key_kind":   role.keyKind(),
+			"role":       roleName,
+			"connection": normalizeConnection(role.Connection),
+			"lease_ref":  leaseRef,
 		},
 	), nil
 }
 
+// newLeaseRef returns a random reference to a lease of a shared key
+func newLeaseRef() (string, error) {
+	ref := make([]byte, 16)
+	if _, err := rand.Read(ref); err != nil {
+		return "", fmt.Errorf("error generating lease reference: %w", err)
+	}
+	return hex.EncodeToString(ref), nil
+}
+
+// rollbackClusterKey deletes a shared key that could not be tracked
+func (b *ccloudBackend) rollbackClusterKey(ctx context.Context, req *logical.Request, role *apikeyRoleEntry, token *ccloudClusterApiKey) error {
+	client, err := b.getClient(ctx, req.Storage, role.Connection)
+	if err != nil {
+		return err
+	}
+	return b.rollbackApiKey(ctx, client, token.KeyId)
+}
+
 // createClusterKey uses the CCloud client to sign in and get a new token
-func (b *ccloudBackend) createClusterKey(ctx context.Context, req *logical.Request, roleEntry *apikeyRoleEntry) (*ccloudClusterApiKey, error) {
-	client, err := b.getClient(ctx, req.Storage)
+func (b *ccloudBackend) createClusterKey(ctx context.Context, req *logical.Request, roleName string, roleEntry *apikeyRoleEntry) (*ccloudClusterApiKey, error) {
+	client, err := b.getClient(ctx, req.Storage, roleEntry.Connection)
 	if err != nil {
 		return nil, err
 	}
 
 	var apiKey *ccloudClusterApiKey
 
-	// TODO Populate Display Name Template
-	displayName := "display_name"
+	templateData, err := newKeyTemplateData(roleName, req.MountPoint, req.EntityID, b.entityName(req.EntityID), req.DisplayName)
+	if err != nil {
+		return nil, err
+	}
+
+	displayName, err := renderKeyTemplate(roleEntry.displayNameTemplate(), templateData)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering display name template: %w", err)
+	}
 
 	description := fmt.Sprintf("Key for role: %s%s (entity=%s, source=Vault CC plugin)", req.MountPoint, req.Path, req.DisplayName)
 	if roleEntry.KeyDescription != "" {
 		description = roleEntry.KeyDescription
 	}
+	if roleEntry.DescriptionTemplate != "" {
+		description, err = renderKeyTemplate(roleEntry.DescriptionTemplate, templateData)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering description template: %w", err)
+		}
+	}
+
+	// rollback undoes, in reverse order, what was created for the key when
+	// a later step fails
+	var rollback []func() error
+	fail := func(err error) (*ccloudClusterApiKey, error) {
+		for i := len(rollback) - 1; i >= 0; i-- {
+			err = errors.Join(err, rollback[i]())
+		}
+		return nil, err
+	}
 
-	apiKey, err = createToken(ctx, client, roleEntry.Owner, roleEntry.OwnerEnv, roleEntry.Resource, roleEntry.ResourceEnv, displayName, description)
+	owner, ownerEnv := roleEntry.Owner, roleEntry.OwnerEnv
+	serviceAccountId := ""
+	if roleEntry.DynamicServiceAccount {
+		serviceAccountName, err := renderKeyTemplate(roleEntry.serviceAccountNameTemplate(), templateData)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering service account name template: %w", err)
+		}
 
-	if err != nil {
-		return nil, fmt.Errorf("error creating CCloud Cluster API token: %w", err)
+		serviceAccountId, err = client.CreateServiceAccount(ctx, serviceAccountName, description)
+		if err != nil {
+			return nil, err
+		}
+		b.Logger().Info("created dynamic service account", "service_account_id", serviceAccountId, "role", roleName)
+		rollback = append(rollback, func() error {
+			return b.rollbackServiceAccount(ctx, client, serviceAccountId)
+		})
+
+		owner, ownerEnv = serviceAccountId, ""
+	}
+
+	if roleEntry.PoolSize > 0 {
+		apiKey, err = b.takePooledKey(ctx, req.Storage, roleName, roleEntry)
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	if apiKey == nil {
+		apiKey, err = createToken(ctx, client, owner, ownerEnv, roleEntry.Resource, roleEntry.ResourceEnv, displayName, description)
+		if err != nil {
+			return fail(fmt.Errorf("error creating CCloud Cluster API token: %w", err))
+		}
+	}
+
+	if apiKey.KeyId != "" {
+		rollback = append(rollback, func() error {
+			return b.rollbackApiKey(ctx, client, apiKey.KeyId)
+		})
 	}
 
 	if apiKey.KeyId == "" || apiKey.Secret == "" {
 		b.Logger().Error("Invalid CCloud API Token")
-		return nil, errors.New("received an invalid CCloud Cluster API token")
-	} else {
-		b.Logger().Info(`Created CC API key: %v`, apiKey.KeyId)
+		return fail(errors.New("received an invalid CCloud Cluster API token"))
+	}
+
+	b.Logger().Info("created CC API key", "key_id", apiKey.KeyId)
+	apiKey.ServiceAccountId = serviceAccountId
+
+	for _, binding := range roleEntry.RoleBindings {
+		bindingId, err := client.CreateRoleBinding(ctx, owner, binding)
+		if err != nil {
+			return fail(err)
+		}
+		rollback = append(rollback, func() error {
+			return b.rollbackRoleBinding(ctx, client, bindingId)
+		})
+		apiKey.RoleBindingIds = append(apiKey.RoleBindingIds, bindingId)
+	}
+
+	if len(roleEntry.ACLs) > 0 {
+		acls := &leaseACLs{
+			Endpoint:  roleEntry.ACLRestEndpoint,
+			ClusterId: roleEntry.Resource,
+			Principal: principalPrefix + owner,
+		}
+		if acls.Endpoint == "" {
+			acls.Endpoint, err = client.KafkaRESTEndpoint(ctx, roleEntry.Resource, roleEntry.ResourceEnv)
+			if err != nil {
+				return fail(err)
+			}
+		}
+
+		for _, acl := range roleEntry.ACLs {
+			if err := client.CreateKafkaACL(ctx, acls.Endpoint, acls.ClusterId, roleEntry.kafkaACLAuth(), acls.Principal, acl); err != nil {
+				return fail(err)
+			}
+			rollback = append(rollback, func() error {
+				return b.rollbackKafkaACL(ctx, client, roleEntry, acls, acl)
+			})
+			acls.ACLs = append(acls.ACLs, acl)
+		}
+		apiKey.ACLs = acls
+	}
+
+	if roleEntry.WaitForReady {
+		if err := b.waitForKey(ctx, client, roleEntry, apiKey.KeyId, apiKey.Secret); err != nil {
+			return fail(err)
+		}
 	}
 
 	return apiKey, nil
 }
 
+// rollbackKafkaACL deletes a Kafka ACL created by a request that failed
+func (b *ccloudBackend) rollbackKafkaACL(ctx context.Context, client *ccloudAPIKeyClient, role *apikeyRoleEntry, acls *leaseACLs, acl kafkaACL) error {
+	if err := client.DeleteKafkaACL(ctx, acls.Endpoint, acls.ClusterId, role.kafkaACLAuth(), acls.Principal, acl); err != nil {
+		b.Logger().Error("failed to roll back Kafka ACL", "principal", acls.Principal, "resource_name", acl.ResourceName, "error", err)
+		return fmt.Errorf("error rolling back Kafka ACL: %w", err)
+	}
+	return nil
+}
+
+// rollbackRoleBinding deletes a role binding created by a request that failed
+func (b *ccloudBackend) rollbackRoleBinding(ctx context.Context, client *ccloudAPIKeyClient, bindingId string) error {
+	if err := client.DeleteRoleBinding(ctx, bindingId); err != nil {
+		b.Logger().Error("failed to roll back role binding", "role_binding_id", bindingId, "error", err)
+		return fmt.Errorf("error rolling back role binding %s: %w", bindingId, err)
+	}
+	return nil
+}
+
+// rollbackApiKey deletes a key created by a request that failed
+func (b *ccloudBackend) rollbackApiKey(ctx context.Context, client *ccloudAPIKeyClient, keyId string) error {
+	if err := client.DeleteApiKey(ctx, keyId); err != nil {
+		b.Logger().Error("failed to roll back API key", "key_id", keyId, "error", err)
+		return fmt.Errorf("error rolling back API key %s: %w", keyId, err)
+	}
+	return nil
+}
+
+// rollbackServiceAccount deletes a dynamic service account created by a
+// request that failed
+func (b *ccloudBackend) rollbackServiceAccount(ctx context.Context, client *ccloudAPIKeyClient, serviceAccountId string) error {
+	if err := client.DeleteServiceAccount(ctx, serviceAccountId); err != nil {
+		b.Logger().Error("failed to roll back service account", "service_account_id", serviceAccountId, "error", err)
+		return fmt.Errorf("error rolling back service account %s: %w", serviceAccountId, err)
+	}
+	return nil
+}
+
+// entityName looks up the name of the entity a request was made by. It is
+// empty if the request has no entity or the entity cannot be looked up.
+func (b *ccloudBackend) entityName(entityID string) string {
+	if entityID == "" {
+		return ""
+	}
+
+	entity, err := b.System().EntityInfo(entityID)
+	if err != nil {
+		b.Logger().Warn("error looking up entity", "entity_id", entityID, "error", err)
+		return ""
+	}
+	if entity == nil {
+		return ""
+	}
+
+	return entity.Name
+}
+
 const pathCredentialsHelpSyn = `
 Generate a Confluent Cloud Cluster API token from a specific Vault role.
 `
@@ -178,4 +412,12 @@ Generate a Confluent Cloud Cluster API token from a specific Vault role.
 const pathCredentialsHelpDesc = `
 This path generates Confluent Cloud Cluster API tokens based on a particular
 role.
+
+If Confluent Cloud refuses to create the key, the request fails with a status
+that tells why: 403 if the backend is not allowed to create it or the API key
+quota is exceeded, 404 if the owner or resource does not exist, 409 on a
+conflict, 429 if the request was rate limited by Confluent Cloud or by the
+backend, 502 if Confluent Cloud rejected the backend's credentials or
+failed, and 504 if the role waits for new keys to be ready and the key was not
+accepted in time.
 `
@@ -2,10 +2,16 @@ package plugin
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
 	"os"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -139,3 +145,765 @@ func TestPathCredentialsReadReturnsRoleIsNilError(t *testing.T) {
 	expectedErrorMsg := "error retrieving role: role is nil"
 	assert.EqualErrorf(t, err, expectedErrorMsg, "Error should be: %v, got: %v", expectedErrorMsg, err)
 }
+
+func TestPathCredentialsUsesRoleConnection(t *testing.T) {
+	defaultFake := newFakeCCloud(t)
+	otherFake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	defaultFake.configure(t, b, s)
+	otherFake.configureConnection(t, b, s, "other")
+
+	_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"connection":   "other",
+		"owner":        owner,
+		"owner_env":    owner_env,
+		"resource":     resource,
+		"resource_env": resource_env,
+	})
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + roleName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp.Secret)
+
+	keyId := resp.Data["key_id"].(string)
+	assert.True(t, otherFake.hasKey(keyId))
+	assert.Equal(t, 1, defaultFake.keyCount())
+	assert.Equal(t, "other", resp.Secret.InternalData["connection"])
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    resp.Secret,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.False(t, otherFake.hasKey(keyId))
+}
+
+func TestPathCredentialsReadReturnsCodedErrors(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	_, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      configStoragePath,
+		Data:      map[string]interface{}{"max_retries": 0},
+		Storage:   s,
+	})
+	require.NoError(t, err)
+
+	_, err = testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"owner":        owner,
+		"owner_env":    owner_env,
+		"resource":     resource,
+		"resource_env": resource_env,
+	})
+	require.NoError(t, err)
+
+	for status, expected := range map[int]int{
+		http.StatusUnauthorized:        http.StatusBadGateway,
+		http.StatusPaymentRequired:     http.StatusForbidden,
+		http.StatusForbidden:           http.StatusForbidden,
+		http.StatusNotFound:            http.StatusNotFound,
+		http.StatusConflict:            http.StatusConflict,
+		http.StatusTooManyRequests:     http.StatusTooManyRequests,
+		http.StatusInternalServerError: http.StatusBadGateway,
+	} {
+		fake.failNext(http.MethodPost, fakeFailure{status: status})
+
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/" + roleName,
+			Storage:   s,
+		})
+		require.Error(t, err, "status %d", status)
+
+		var coded logical.HTTPCodedError
+		require.ErrorAs(t, err, &coded, "status %d", status)
+		assert.Equal(t, expected, coded.Code(), "status %d", status)
+		assert.Contains(t, err.Error(), http.StatusText(status))
+	}
+}
+
+func TestPathCredentialsRendersKeyTemplates(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	createCreds := func() string {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        "creds/" + roleName,
+			Storage:     s,
+			MountPoint:  "ccloud/",
+			DisplayName: "token-app",
+			EntityID:    "entity-1",
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+		return resp.Data["key_id"].(string)
+	}
+
+	_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"owner":        owner,
+		"owner_env":    owner_env,
+		"resource":     resource,
+		"resource_env": resource_env,
+	})
+	require.NoError(t, err)
+
+	key := fake.key(createCreds())
+	assert.Regexp(t, "^vault-"+roleName+"-[0-9a-f]{8}$", key.DisplayName)
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/" + roleName,
+		Data: map[string]interface{}{
+			"display_name_template": "{{.RoleName}}-{{.DisplayName}}-{{.RandomSuffix}}",
+			"description_template":  "used by {{.EntityID}} through {{.MountPoint}}",
+		},
+		Storage: s,
+	})
+	require.NoError(t, err)
+
+	key = fake.key(createCreds())
+	assert.Regexp(t, "^"+roleName+"-token-app-[0-9a-f]{8}$", key.DisplayName)
+	assert.Equal(t, "used by entity-1 through ccloud/", key.Description)
+}
+
+func TestPathCredentialsReturnsKindSpecificData(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	for kind, role := range map[string]map[string]interface{}{
+		keyKindCloud:          {},
+		keyKindKafka:          {"resource": "lkc-1", "resource_env": "env-1"},
+		keyKindSchemaRegistry: {"resource": "lsrc-1", "resource_env": "env-1"},
+		keyKindFlink:          {"resource": "aws.us-east-1", "resource_env": "env-1"},
+	} {
+		role["key_kind"] = kind
+		role["owner"] = fakeRootKeyOwner
+		resp, err := testTokenRoleCreate(t, b, s, kind, role)
+		require.NoError(t, err)
+		require.Nil(t, resp, kind)
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/" + kind,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+
+		keyId := resp.Data["key_id"].(string)
+		secret := resp.Data["secret"].(string)
+		assert.Equal(t, kind, resp.Data["key_kind"])
+		assert.Equal(t, kind, resp.Secret.InternalData["key_kind"])
+		resource, _ := role["resource"].(string)
+		assert.Equal(t, resource, fake.key(keyId).Resource, kind)
+
+		switch kind {
+		case keyKindKafka:
+			assert.Contains(t, resp.Data["sasl.jaas.config"], "username='"+keyId+"'")
+		case keyKindSchemaRegistry:
+			assert.Equal(t, keyId+":"+secret, resp.Data["basic.auth.user.info"])
+			assert.NotContains(t, resp.Data, "sasl.jaas.config")
+		default:
+			assert.NotContains(t, resp.Data, "sasl.jaas.config")
+			assert.NotContains(t, resp.Data, "basic.auth.user.info")
+		}
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		assert.False(t, fake.hasKey(keyId), kind)
+	}
+}
+
+func TestPathCredentialsCreatesDynamicServiceAccounts(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"key_kind":                "cloud",
+		"dynamic_service_account": true,
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + roleName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp.Secret)
+
+	keyId := resp.Data["key_id"].(string)
+	serviceAccountId := resp.Data["service_account_id"].(string)
+	assert.Equal(t, serviceAccountId, fake.key(keyId).Owner)
+	assert.True(t, fake.hasObject(serviceAccountsPath+"/"+serviceAccountId))
+
+	// a failed revocation of the service account is retried by Vault, after
+	// the key was already deleted
+	fake.failNext(http.MethodDelete, fakeFailure{status: http.StatusBadRequest, path: serviceAccountsPath + "/" + serviceAccountId})
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    resp.Secret,
+		Storage:   s,
+	})
+	require.Error(t, err)
+	assert.False(t, fake.hasKey(keyId))
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    resp.Secret,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.False(t, fake.hasObject(serviceAccountsPath+"/"+serviceAccountId))
+}
+
+func TestPathCredentialsRollsBackDynamicServiceAccounts(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"key_kind":                "cloud",
+		"dynamic_service_account": true,
+	})
+	require.NoError(t, err)
+
+	fake.failNext(http.MethodPost, fakeFailure{status: http.StatusBadRequest, path: "/iam/v2/api-keys"})
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + roleName,
+		Storage:   s,
+	})
+	require.Error(t, err)
+
+	assert.False(t, fake.hasObject(serviceAccountsPath+"/sa-dyn1"))
+	assert.Equal(t, 1, fake.keyCount())
+}
+
+func TestPathCredentialsBindsRolesForTheLease(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	const topics = "crn://confluent.cloud/organization=o-1/environment=env-1/cloud-cluster=lkc-1/kafka=lkc-1/topic=orders-*"
+	resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"key_kind":                "kafka",
+		"dynamic_service_account": true,
+		"resource":                "lkc-1",
+		"resource_env":            "env-1",
+		"role_bindings": []interface{}{
+			"DeveloperRead=" + topics,
+			map[string]interface{}{"role_name": "DeveloperWrite", "crn_pattern": topics},
+		},
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + roleName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp.Secret)
+
+	principal := "User:" + resp.Data["service_account_id"].(string)
+	assert.Equal(t, []fakeRoleBinding{
+		{Principal: principal, RoleName: "DeveloperRead", CRNPattern: topics},
+		{Principal: principal, RoleName: "DeveloperWrite", CRNPattern: topics},
+	}, fake.bindings(principal))
+	assert.Len(t, resp.Secret.InternalData["role_binding_ids"], 2)
+
+	// another lease binds the roles to its own principal
+	other, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + roleName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	otherPrincipal := "User:" + other.Data["service_account_id"].(string)
+	assert.NotEqual(t, principal, otherPrincipal)
+	assert.Len(t, fake.bindings(otherPrincipal), 2)
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    resp.Secret,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, fake.bindings(principal))
+	assert.Len(t, fake.bindings(otherPrincipal), 2)
+	assert.Equal(t, 2, fake.keyCount())
+}
+
+func TestPathCredentialsRollsBackRoleBindings(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	const cluster = "crn://confluent.cloud/organization=o-1/environment=env-1/cloud-cluster=lkc-1"
+	_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"key_kind":                "cloud",
+		"dynamic_service_account": true,
+		"role_bindings": []interface{}{
+			"DeveloperRead=" + cluster,
+			"DeveloperWrite=" + cluster,
+		},
+	})
+	require.NoError(t, err)
+
+	fake.failNext(http.MethodPost, fakeFailure{status: http.StatusBadRequest, path: roleBindingsPath, skip: 1})
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + roleName,
+		Storage:   s,
+	})
+	require.Error(t, err)
+
+	assert.Empty(t, fake.bindings("User:sa-dyn1"))
+	assert.False(t, fake.hasObject(serviceAccountsPath+"/sa-dyn1"))
+	assert.Equal(t, 1, fake.keyCount())
+}
+
+func TestPathCredentialsCreatesKafkaACLsForTheLease(t *testing.T) {
+	fake := newFakeCCloud(t)
+	rest := newFakeKafkaREST(t, "lkc-1")
+	fake.addKafkaCluster("lkc-1", "env-1", rest.URL)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"key_kind":                "kafka",
+		"dynamic_service_account": true,
+		"resource":                "lkc-1",
+		"resource_env":            "env-1",
+		"acl_api_key_id":          fakeKafkaAdminKeyId,
+		"acl_api_key_secret":      fakeKafkaAdminKeySecret,
+		"acls": []interface{}{
+			map[string]interface{}{"resource_type": "topic", "resource_name": "orders-", "pattern_type": "prefixed", "operation": "read"},
+			map[string]interface{}{"resource_type": "GROUP", "resource_name": "orders-app", "operation": "DESCRIBE"},
+		},
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + roleName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp.Secret)
+
+	principal := "User:" + resp.Data["service_account_id"].(string)
+	assert.Equal(t, []kafkaACL{
+		{ResourceType: "GROUP", ResourceName: "orders-app", PatternType: "LITERAL", Operation: "DESCRIBE", Permission: "ALLOW", Host: "*"},
+		{ResourceType: "TOPIC", ResourceName: "orders-", PatternType: "PREFIXED", Operation: "READ", Permission: "ALLOW", Host: "*"},
+	}, rest.aclsOf(principal))
+
+	// an ACL the lease did not create is left alone
+	rest.addACL(principal, kafkaACL{ResourceType: "TOPIC", ResourceName: "audit", PatternType: "LITERAL", Operation: "WRITE", Permission: "ALLOW", Host: "*"})
+
+	// the lease is revoked with its internal data as stored by Vault
+	encoded, err := json.Marshal(resp.Secret.InternalData)
+	require.NoError(t, err)
+	resp.Secret.InternalData = nil
+	require.NoError(t, json.Unmarshal(encoded, &resp.Secret.InternalData))
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    resp.Secret,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []kafkaACL{
+		{ResourceType: "TOPIC", ResourceName: "audit", PatternType: "LITERAL", Operation: "WRITE", Permission: "ALLOW", Host: "*"},
+	}, rest.aclsOf(principal))
+}
+
+func TestPathCredentialsManagesKafkaACLsWithTheClusterKey(t *testing.T) {
+	fake := newFakeCCloud(t)
+	rest := newFakeKafkaREST(t, "lkc-1")
+	fake.addKafkaCluster("lkc-1", "env-1", rest.URL)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	data := map[string]interface{}{
+		"key_kind":                "kafka",
+		"dynamic_service_account": true,
+		"resource":                "lkc-1",
+		"resource_env":            "env-1",
+		"acl_api_key_id":          fakeKafkaAdminKeyId,
+		"acl_api_key_secret":      fakeKafkaAdminKeySecret,
+		"acls": []interface{}{
+			map[string]interface{}{"resource_type": "TOPIC", "resource_name": "orders", "operation": "READ"},
+		},
+	}
+	resp, err := testTokenRoleCreate(t, b, s, roleName, data)
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + roleName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp.Secret)
+	secret := resp.Secret
+
+	// the cluster key, not the Cloud API key of the connection, is used,
+	// and its secret is not stored with the lease
+	assert.Equal(t, []string{fakeKafkaAdminKeyId}, rest.callersOf(http.MethodPost))
+	encoded, err := json.Marshal(secret.InternalData)
+	require.NoError(t, err)
+	assert.NotContains(t, string(encoded), fakeKafkaAdminKeySecret)
+
+	// the lease deletes its ACLs with the key the role has when it is
+	// revoked, so the key can be rotated
+	rest.rotateAdminKey("ROTATEDKEY", "rotated-secret")
+	data["acl_api_key_id"] = "ROTATEDKEY"
+	data["acl_api_key_secret"] = "rotated-secret"
+	resp, err = testTokenRoleCreate(t, b, s, roleName, data)
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    secret,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ROTATEDKEY"}, rest.callersOf(http.MethodDelete))
+	assert.Empty(t, rest.aclsOf("User:"+secret.InternalData["service_account_id"].(string)))
+
+	// credentials of a role with a key the cluster rejects are not issued
+	data["acl_api_key_id"] = "OTHERKEY"
+	data["acl_api_key_secret"] = "other-secret"
+	resp, err = testTokenRoleCreate(t, b, s, roleName, data)
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + roleName,
+		Storage:   s,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+	assert.Equal(t, []string{fakeKafkaAdminKeyId, "OTHERKEY"}, rest.callersOf(http.MethodPost))
+}
+
+func TestPathCredentialsWaitsForKeyToBeReady(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"key_kind":       "cloud",
+		"owner":          fakeRootKeyOwner,
+		"wait_for_ready": true,
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	fake.delayNewKeys(2)
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + roleName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp.Secret)
+
+	// the key was returned only after it was accepted
+	assert.Zero(t, fake.key(resp.Data["key_id"].(string)).rejections)
+}
+
+func TestPathCredentialsFailsWhenKeyIsNotReady(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"key_kind":       "cloud",
+		"owner":          fakeRootKeyOwner,
+		"wait_for_ready": true,
+		"ready_timeout":  1,
+	})
+	require.NoError(t, err)
+
+	fake.delayNewKeys(1000)
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + roleName,
+		Storage:   s,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "was not accepted")
+
+	var coded logical.HTTPCodedError
+	require.ErrorAs(t, err, &coded)
+	assert.Equal(t, http.StatusGatewayTimeout, coded.Code())
+
+	// the key that never became ready was deleted
+	assert.Equal(t, 1, fake.keyCount())
+}
+
+func TestRoleWriteValidatesWaitForReady(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	for _, tc := range []struct {
+		data     map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"key_kind": "cloud", "owner": fakeRootKeyOwner, "wait_for_ready": true, "ready_timeout": -1}, "cannot provide negative value"},
+		{map[string]interface{}{"key_kind": "flink", "owner": fakeRootKeyOwner, "resource": "aws.us-east-1", "resource_env": "env-1", "wait_for_ready": true}, "not supported for flink keys"},
+	} {
+		resp, err := testTokenRoleCreate(t, b, s, roleName, tc.data)
+		require.NoError(t, err)
+		require.True(t, resp.IsError(), tc.expected)
+		assert.Contains(t, resp.Error().Error(), tc.expected)
+	}
+}
+
+func TestPathCredentialsReplacesAgedSharedKey(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"key_kind":           "cloud",
+		"owner":              fakeRootKeyOwner,
+		"multi_use_key":      true,
+		"shared_key_max_age": "1h",
+	})
+	require.NoError(t, err)
+
+	readCreds := func() *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/" + roleName,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+		return resp
+	}
+	revoke := func(resp *logical.Response) {
+		t.Helper()
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+	}
+
+	first, second := readCreds(), readCreds()
+	oldKeyId := first.Data["key_id"].(string)
+	assert.Equal(t, oldKeyId, second.Data["key_id"])
+
+	role, err := b.getRole(context.Background(), s, roleName)
+	require.NoError(t, err)
+	role.SharedKeys[0].CreatedAt = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, setRole(context.Background(), s, roleName, role))
+
+	// new leases get a new key, while the old one keeps working
+	third := readCreds()
+	newKeyId := third.Data["key_id"].(string)
+	assert.NotEqual(t, oldKeyId, newKeyId)
+	assert.Equal(t, newKeyId, readCreds().Data["key_id"])
+	assert.True(t, fake.hasKey(oldKeyId))
+
+	resp, err := testTokenRoleRead(t, b, s)
+	require.NoError(t, err)
+	assert.Equal(t, newKeyId, resp.Data["cc_key_id"])
+	assert.Equal(t, 2, resp.Data["usage_count"])
+	require.Len(t, resp.Data["shared_keys"], 2)
+	assert.Equal(t, oldKeyId, resp.Data["shared_keys"].([]map[string]interface{})[0]["key_id"])
+
+	// the old key is deleted once its own leases are revoked
+	revoke(first)
+	assert.True(t, fake.hasKey(oldKeyId))
+	revoke(second)
+	assert.False(t, fake.hasKey(oldKeyId))
+	assert.True(t, fake.hasKey(newKeyId))
+
+	revoke(third)
+	assert.True(t, fake.hasKey(newKeyId))
+	role, err = b.getRole(context.Background(), s, roleName)
+	require.NoError(t, err)
+	require.Len(t, role.SharedKeys, 1)
+	assert.Equal(t, 1, role.SharedKeys[0].usageCount())
+}
+
+func TestPathCredentialsTracksSharedKeyLeasesConcurrently(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"key_kind":      "cloud",
+		"owner":         fakeRootKeyOwner,
+		"multi_use_key": true,
+	})
+	require.NoError(t, err)
+
+	readCreds := func() (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/" + roleName,
+			Storage:   s,
+		})
+	}
+	revoke := func(secret *logical.Secret) error {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    secret,
+			Storage:   s,
+		})
+		return err
+	}
+
+	const workers = 16
+
+	// concurrent first requests share a single new key
+	leases := make([]*logical.Response, workers)
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := readCreds()
+			if assert.NoError(t, err) {
+				leases[i] = resp
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.NotNil(t, leases[0])
+	keyId := leases[0].Data["key_id"]
+	for _, lease := range leases {
+		require.NotNil(t, lease)
+		assert.Equal(t, keyId, lease.Data["key_id"])
+	}
+	assert.Equal(t, 2, fake.keyCount())
+
+	deleteRole := func() *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      "role/" + roleName,
+			Storage:   s,
+		})
+		assert.NoError(t, err)
+		return resp
+	}
+
+	// the role, which tracks the leases of its shared key, cannot be
+	// deleted while they use it
+	resp := deleteRole()
+	require.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), fmt.Sprintf("in use by %d leases", workers))
+
+	// leases are issued and revoked concurrently, and some are revoked again
+	// as Vault does when a revocation fails, while the role is deleted
+	var deleted atomic.Bool
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i == 0 {
+				deleted.Store(!deleteRole().IsError())
+			}
+			assert.NoError(t, revoke(leases[i].Secret))
+			for range 5 {
+				resp, err := readCreds()
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.NoError(t, revoke(resp.Secret))
+				if i%2 == 0 {
+					assert.NoError(t, revoke(resp.Secret))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.False(t, deleted.Load(), "the role was deleted while leases used its shared key")
+
+	role, err := b.getRole(context.Background(), s, roleName)
+	require.NoError(t, err)
+	assert.Empty(t, role.SharedKeys)
+	assert.Equal(t, 1, fake.keyCount())
+
+	// once they are revoked it can
+	assert.Nil(t, deleteRole())
+}
+
+func TestPathCredentialsReleasesUntrackedSharedKeyLeases(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"key_kind":      "cloud",
+		"owner":         fakeRootKeyOwner,
+		"multi_use_key": true,
+	})
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + roleName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	keyId := resp.Data["key_id"].(string)
+
+	// two leases issued before leases were tracked use the key as well
+	role, err := b.getRole(context.Background(), s, roleName)
+	require.NoError(t, err)
+	role.SharedKeys[0].UntrackedLeases = 2
+	require.NoError(t, setRole(context.Background(), s, roleName, role))
+
+	for _, secret := range []*logical.Secret{
+		resp.Secret,
+		{InternalData: map[string]interface{}{"secret_type": ccloudClusterApiKeyType, "key_id": keyId, "role": roleName}},
+	} {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    secret,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		assert.True(t, fake.hasKey(keyId))
+	}
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    &logical.Secret{InternalData: map[string]interface{}{"secret_type": ccloudClusterApiKeyType, "key_id": keyId, "role": roleName}},
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.False(t, fake.hasKey(keyId))
+}
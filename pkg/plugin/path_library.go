@@ -0,0 +1,496 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	libraryStoragePrefix = "library/"
+
+	// defaultLibraryMinAge is how long a library key ages before it is
+	// checked out, so brokers accept it right away
+	defaultLibraryMinAge = time.Minute
+)
+
+// librarySetEntry is a pool of pre-created API keys that are checked out
+// exclusively and replaced when they are checked in
+type librarySetEntry struct {
+	Connection string `json:"connection,omitempty"`
+
+	KeyKind     string `json:"key_kind"`
+	Owner       string `json:"owner"`
+	OwnerEnv    string `json:"owner_env,omitempty"`
+	Resource    string `json:"resource,omitempty"`
+	ResourceEnv string `json:"resource_env,omitempty"`
+
+	Size   int           `json:"size"`
+	TTL    time.Duration `json:"ttl,omitempty"`
+	MaxTTL time.Duration `json:"max_ttl,omitempty"`
+
+	// MinAge is how long new keys age before they are checked out
+	MinAge time.Duration `json:"min_age,omitempty"`
+
+	// Keys are managed by the backend
+	Keys []libraryKey `json:"keys,omitempty"`
+}
+
+// libraryKey is a key of a library set and who has checked it out
+type libraryKey struct {
+	KeyId     string    `json:"key_id"`
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+
+	CheckedOut   bool      `json:"checked_out,omitempty"`
+	EntityID     string    `json:"entity_id,omitempty"`
+	CheckedOutAt time.Time `json:"checked_out_at,omitempty"`
+}
+
+func (s *librarySetEntry) toResponseData() map[string]interface{} {
+	return map[string]interface{}{
+		"connection":   normalizeConnection(s.Connection),
+		"key_kind":     s.KeyKind,
+		"owner":        s.Owner,
+		"owner_env":    s.OwnerEnv,
+		"resource":     s.Resource,
+		"resource_env": s.ResourceEnv,
+		"size":         s.Size,
+		"ttl":          int64(s.TTL.Seconds()),
+		"max_ttl":      int64(s.MaxTTL.Seconds()),
+		"min_age":      int64(s.minAge().Seconds()),
+	}
+}
+
+// minAge returns how long the set's keys age before they are checked out
+func (s *librarySetEntry) minAge() time.Duration {
+	if s.MinAge > 0 {
+		return s.MinAge
+	}
+	return defaultLibraryMinAge
+}
+
+// isAged reports whether a key is old enough to be checked out. Keys stored
+// before their creation time was recorded are.
+func (k *libraryKey) isAged(minAge time.Duration) bool {
+	return k.CreatedAt.IsZero() || time.Since(k.CreatedAt) >= minAge
+}
+
+// findKey returns the index of a key in the set, or -1
+func (s *librarySetEntry) findKey(keyId string) int {
+	for i, key := range s.Keys {
+		if key.KeyId == keyId {
+			return i
+		}
+	}
+	return -1
+}
+
+// pathLibrary extends the Vault API with `/library` endpoints that manage
+// sets of pre-created keys.
+func pathLibrary(b *ccloudBackend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: libraryStoragePrefix + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the library set",
+					Required:    true,
+				},
+				"connection": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the connection used to manage the set's keys. If not set, the default connection is used.",
+				},
+				"key_kind": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Kind of API keys in the set: cloud, kafka, schema_registry, ksqldb or flink.",
+					Required:    true,
+				},
+				"owner": {
+					Type:        framework.TypeString,
+					Description: "Confluent Cloud ID of the service account which owns the keys.",
+					Required:    true,
+				},
+				"owner_env": {
+					Type:        framework.TypeString,
+					Description: "The owner's CCloud Environment ID, if env-scoped.",
+				},
+				"resource": {
+					Type:        framework.TypeString,
+					Description: "Confluent Cloud ID of the cluster, or the Flink region, for which the keys are created. Not used for Cloud API keys.",
+				},
+				"resource_env": {
+					Type:        framework.TypeString,
+					Description: "The resource's CCloud Environment ID.",
+				},
+				"size": {
+					Type:        framework.TypeInt,
+					Description: "Number of keys in the set.",
+					Required:    true,
+				},
+				"ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Default lease of a check-out. If not set or set to 0, will use system default.",
+				},
+				"max_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Maximum lease of a check-out. If not set or set to 0, will use system default.",
+				},
+				"min_age": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How long new keys age before they are checked out. Defaults to 60 seconds.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathLibraryRead,
+				},
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathLibraryWrite,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathLibraryWrite,
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathLibraryDelete,
+				},
+			},
+			HelpSynopsis:    pathLibraryHelpSynopsis,
+			HelpDescription: pathLibraryHelpDescription,
+			ExistenceCheck:  b.pathRoleExistenceCheck,
+		},
+		{
+			Pattern: libraryStoragePrefix + "?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathLibraryList,
+				},
+			},
+			HelpSynopsis:    pathLibraryListHelpSynopsis,
+			HelpDescription: pathLibraryListHelpDescription,
+		},
+		{
+			Pattern: libraryStoragePrefix + framework.GenericNameRegex("name") + "/status$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the library set",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathLibraryStatus,
+				},
+			},
+			HelpSynopsis:    pathLibraryStatusHelpSynopsis,
+			HelpDescription: pathLibraryStatusHelpDescription,
+		},
+	}
+}
+
+// pathLibraryList lists the library sets
+func (b *ccloudBackend) pathLibraryList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, libraryStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+// pathLibraryRead returns the configuration of a library set
+func (b *ccloudBackend) pathLibraryRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	set, err := getLibrarySet(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if set == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: set.toResponseData(),
+	}, nil
+}
+
+// pathLibraryStatus reports which keys of a set are available, and which
+// are checked out, by which entity and since when
+func (b *ccloudBackend) pathLibraryStatus(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	set, err := getLibrarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if set == nil {
+		return logical.ErrorResponse("unknown library set: %s", name), nil
+	}
+
+	status := make(map[string]interface{}, len(set.Keys))
+	for _, key := range set.Keys {
+		keyStatus := map[string]interface{}{
+			"available": !key.CheckedOut,
+		}
+		if !key.CreatedAt.IsZero() {
+			keyStatus["created_at"] = key.CreatedAt.Format(time.RFC3339)
+		}
+		if key.CheckedOut {
+			keyStatus["entity_id"] = key.EntityID
+			keyStatus["checked_out_at"] = key.CheckedOutAt.Format(time.RFC3339)
+		}
+		status[key.KeyId] = keyStatus
+	}
+
+	return &logical.Response{
+		Data: status,
+	}, nil
+}
+
+// pathLibraryWrite creates or updates a library set. Keys are created until
+// the set has its size; available keys beyond the size are deleted, and
+// checked out ones when they are checked in.
+func (b *ccloudBackend) pathLibraryWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	set, err := getLibrarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	createOperation := set == nil
+	if createOperation {
+		set = &librarySetEntry{}
+	}
+	previous := *set
+
+	if connection, ok := d.GetOk("connection"); ok {
+		set.Connection = connection.(string)
+	}
+
+	for _, field := range []struct {
+		name     string
+		value    *string
+		required bool
+	}{
+		{"key_kind", &set.KeyKind, true},
+		{"owner", &set.Owner, true},
+		{"owner_env", &set.OwnerEnv, false},
+		{"resource", &set.Resource, false},
+		{"resource_env", &set.ResourceEnv, false},
+	} {
+		if value, ok := d.GetOk(field.name); ok {
+			*field.value = value.(string)
+		} else if field.required && createOperation {
+			return logical.ErrorResponse("missing %s in library set", field.name), nil
+		}
+	}
+
+	if size, ok := d.GetOk("size"); ok {
+		set.Size = size.(int)
+	} else if createOperation {
+		return logical.ErrorResponse("missing size in library set"), nil
+	}
+
+	if ttl, ok := d.GetOk("ttl"); ok {
+		set.TTL = time.Duration(ttl.(int)) * time.Second
+	}
+	if maxTTL, ok := d.GetOk("max_ttl"); ok {
+		set.MaxTTL = time.Duration(maxTTL.(int)) * time.Second
+	}
+	if minAge, ok := d.GetOk("min_age"); ok {
+		set.MinAge = time.Duration(minAge.(int)) * time.Second
+	}
+
+	if !hasPrefix(set.Owner, serviceAccountPrefix) {
+		return logical.ErrorResponse("owner %q is not a service account (%s)", set.Owner, serviceAccountPrefix), nil
+	}
+	if err := validateKeyKind(set.KeyKind, set.Resource, set.ResourceEnv); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	if set.Size < 1 {
+		return logical.ErrorResponse("size must be at least 1"), nil
+	}
+	if set.MaxTTL != 0 && set.TTL > set.MaxTTL {
+		return logical.ErrorResponse("ttl cannot be greater than max_ttl"), nil
+	}
+
+	// the keys of a set all belong to the same owner and resource
+	if !createOperation && (normalizeConnection(set.Connection) != normalizeConnection(previous.Connection) ||
+		set.KeyKind != previous.KeyKind ||
+		set.Owner != previous.Owner ||
+		set.OwnerEnv != previous.OwnerEnv ||
+		set.Resource != previous.Resource ||
+		set.ResourceEnv != previous.ResourceEnv) {
+		return logical.ErrorResponse("the connection, key kind, owner and resource of a library set cannot be changed"), nil
+	}
+
+	client, err := b.getClient(ctx, req.Storage, set.Connection)
+	if err != nil {
+		return nil, err
+	}
+
+	// the set is stored after every key that is created or deleted, so keys
+	// are not lost if a later call fails
+	for len(set.Keys) < set.Size {
+		keyId, secret, err := b.createLibraryKey(ctx, client, name, set)
+		if err != nil {
+			return nil, codedError(err)
+		}
+
+		set.Keys = append(set.Keys, libraryKey{KeyId: keyId, Secret: secret, CreatedAt: time.Now()})
+		if err := setLibrarySet(ctx, req.Storage, name, set); err != nil {
+			return nil, errors.Join(err, b.rollbackApiKey(ctx, client, keyId))
+		}
+	}
+
+	for i := len(set.Keys) - 1; i >= 0 && len(set.Keys) > set.Size; i-- {
+		if set.Keys[i].CheckedOut {
+			continue
+		}
+
+		if err := client.DeleteApiKey(ctx, set.Keys[i].KeyId); err != nil && !errors.Is(err, errCCloudNotFound) {
+			err = codedError(err)
+			if saveErr := setLibrarySet(ctx, req.Storage, name, set); saveErr != nil {
+				err = errors.Join(err, saveErr)
+			}
+			return nil, err
+		}
+		set.Keys = append(set.Keys[:i], set.Keys[i+1:]...)
+	}
+
+	if err := setLibrarySet(ctx, req.Storage, name, set); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// pathLibraryDelete deletes the keys of a library set and then the set. A
+// set cannot be deleted while keys are checked out.
+func (b *ccloudBackend) pathLibraryDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	set, err := getLibrarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if set == nil {
+		return nil, nil
+	}
+
+	for _, key := range set.Keys {
+		if key.CheckedOut {
+			return logical.ErrorResponse("key %s of library set %s is checked out", key.KeyId, name), nil
+		}
+	}
+
+	client, err := b.getClient(ctx, req.Storage, set.Connection)
+	if err != nil {
+		return nil, err
+	}
+
+	for len(set.Keys) > 0 {
+		if err := client.DeleteApiKey(ctx, set.Keys[0].KeyId); err != nil && !errors.Is(err, errCCloudNotFound) {
+			if saveErr := setLibrarySet(ctx, req.Storage, name, set); saveErr != nil {
+				err = errors.Join(err, saveErr)
+			}
+			return nil, err
+		}
+		set.Keys = set.Keys[1:]
+	}
+
+	if err := req.Storage.Delete(ctx, libraryStoragePrefix+name); err != nil {
+		return nil, fmt.Errorf("error deleting library set: %w", err)
+	}
+
+	return nil, nil
+}
+
+// createLibraryKey creates a key for a library set
+func (b *ccloudBackend) createLibraryKey(ctx context.Context, client *ccloudAPIKeyClient, name string, set *librarySetEntry) (string, string, error) {
+	displayName := "vault-library-" + name
+	description := fmt.Sprintf("Key of library set %s, managed by the Vault Confluent Cloud secrets engine", name)
+
+	return client.CreateApiKey(ctx, set.Owner, set.OwnerEnv, set.Resource, set.ResourceEnv, displayName, description)
+}
+
+// setLibrarySet stores a library set
+func setLibrarySet(ctx context.Context, s logical.Storage, name string, set *librarySetEntry) error {
+	entry, err := logical.StorageEntryJSON(libraryStoragePrefix+name, set)
+	if err != nil {
+		return err
+	}
+
+	if entry == nil {
+		return fmt.Errorf("failed to create storage entry for library set")
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// getLibrarySet reads a library set. It returns nil if the set does not
+// exist.
+func getLibrarySet(ctx context.Context, s logical.Storage, name string) (*librarySetEntry, error) {
+	if name == "" {
+		return nil, fmt.Errorf("missing library set name")
+	}
+
+	entry, err := s.Get(ctx, libraryStoragePrefix+name)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var set librarySetEntry
+	if err := entry.DecodeJSON(&set); err != nil {
+		return nil, err
+	}
+
+	return &set, nil
+}
+
+const (
+	pathLibraryHelpSynopsis    = `Manages library sets of pre-created API keys that are checked out exclusively.`
+	pathLibraryHelpDescription = `
+A library set keeps "size" API keys of a service account ready, so they can be
+handed out without waiting for a new key to propagate through Confluent
+Cloud. "library/<name>/check-out" hands out an available key exclusively,
+with a lease of "ttl". When the key is checked in with
+"library/<name>/check-in", or its lease expires or is revoked, the key is
+replaced by a new one and deleted, so its secret is never handed out twice.
+Newly created keys are checked out only once they are "min_age" old, so that
+brokers accept them; until then, a check-out fails as if they were checked
+out.
+
+"library/<name>/status" reports which keys are available and which are
+checked out, by which entity and since when. A set cannot be deleted while
+keys are checked out; deleting it deletes its keys.
+`
+
+	pathLibraryListHelpSynopsis    = `List the library sets.`
+	pathLibraryListHelpDescription = `Library sets will be listed by name.`
+
+	pathLibraryStatusHelpSynopsis    = `Report which keys of a library set are checked out.`
+	pathLibraryStatusHelpDescription = `
+Returns every key of the set by ID, whether it is available, when it was
+created, and for checked out keys the entity that checked it out and when.
+`
+)
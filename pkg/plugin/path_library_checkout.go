@@ -0,0 +1,313 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const ccloudLibraryKeyType = "ccloud_library_key"
+
+// errNoKeyAvailable is returned when all keys of a library set are checked
+// out
+var errNoKeyAvailable = errors.New("no key available")
+
+// pathLibraryCheckOut extends the Vault API with endpoints that check keys
+// of a library set out and in.
+func pathLibraryCheckOut(b *ccloudBackend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: libraryStoragePrefix + framework.GenericNameRegex("name") + "/check-out$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the library set",
+					Required:    true,
+				},
+				"ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Lease of the check-out, up to the set's max_ttl. Defaults to the set's ttl.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathLibraryCheckOut,
+				},
+			},
+			HelpSynopsis:    pathLibraryCheckOutHelpSynopsis,
+			HelpDescription: pathLibraryCheckOutHelpDescription,
+		},
+		{
+			Pattern: libraryStoragePrefix + framework.GenericNameRegex("name") + "/check-in$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the library set",
+					Required:    true,
+				},
+				"key_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "IDs of the keys to check in. Defaults to all keys checked out by the requesting entity.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathLibraryCheckIn,
+				},
+			},
+			HelpSynopsis:    pathLibraryCheckInHelpSynopsis,
+			HelpDescription: pathLibraryCheckInHelpDescription,
+		},
+	}
+}
+
+// ccloudLibraryKey defines the secret of a checked out library key, which
+// is checked in when its lease ends
+func (b *ccloudBackend) ccloudLibraryKey() *framework.Secret {
+	return &framework.Secret{
+		Type: ccloudLibraryKeyType,
+		Fields: map[string]*framework.FieldSchema{
+			"key_id": {
+				Type:        framework.TypeString,
+				Description: "Confluent Cloud API Key ID",
+			},
+			"secret": {
+				Type:        framework.TypeString,
+				Description: "Confluent Cloud API Key Secret",
+			},
+		},
+		Revoke: b.libraryKeyRevoke,
+		Renew:  b.libraryKeyRenew,
+	}
+}
+
+// pathLibraryCheckOut hands out an available key of a set exclusively
+func (b *ccloudBackend) pathLibraryCheckOut(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	set, err := getLibrarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if set == nil {
+		return logical.ErrorResponse("unknown library set: %s", name), nil
+	}
+
+	ttl := set.TTL
+	if ttlRaw, ok := d.GetOk("ttl"); ok {
+		ttl = time.Duration(ttlRaw.(int)) * time.Second
+		if set.MaxTTL > 0 && ttl > set.MaxTTL {
+			return logical.ErrorResponse("ttl cannot be greater than the set's max_ttl of %s", set.MaxTTL), nil
+		}
+	}
+
+	// keys are handed out only once brokers accept them
+	i := -1
+	for j, key := range set.Keys {
+		if !key.CheckedOut && key.isAged(set.minAge()) {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		return nil, logical.CodedError(http.StatusTooManyRequests, fmt.Sprintf("library set %s: %s", name, errNoKeyAvailable))
+	}
+
+	key := &set.Keys[i]
+	key.CheckedOut = true
+	key.EntityID = req.EntityID
+	key.CheckedOutAt = time.Now()
+
+	if err := setLibrarySet(ctx, req.Storage, name, set); err != nil {
+		return nil, err
+	}
+
+	resp := b.Secret(ccloudLibraryKeyType).Response(
+		credentialData(set.KeyKind, key.KeyId, key.Secret),
+		map[string]interface{}{
+			"set":    name,
+			"key_id": key.KeyId,
+		},
+	)
+	resp.Secret.TTL = ttl
+	resp.Secret.MaxTTL = set.MaxTTL
+
+	return resp, nil
+}
+
+// pathLibraryCheckIn checks keys back into a set. Entities can only check
+// in the keys they checked out; other check-outs end with their lease.
+func (b *ccloudBackend) pathLibraryCheckIn(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	set, err := getLibrarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if set == nil {
+		return logical.ErrorResponse("unknown library set: %s", name), nil
+	}
+
+	var keyIds []string
+	if keyIdsRaw, ok := d.GetOk("key_ids"); ok {
+		keyIds = keyIdsRaw.([]string)
+		for _, keyId := range keyIds {
+			i := set.findKey(keyId)
+			if i < 0 || !set.Keys[i].CheckedOut {
+				return logical.ErrorResponse("key %s is not checked out of library set %s", keyId, name), nil
+			}
+			if set.Keys[i].EntityID != req.EntityID {
+				return logical.ErrorResponse("key %s was checked out by another entity", keyId), nil
+			}
+		}
+	} else {
+		for _, key := range set.Keys {
+			if key.CheckedOut && key.EntityID == req.EntityID {
+				keyIds = append(keyIds, key.KeyId)
+			}
+		}
+	}
+
+	var checkedIn []string
+	for _, keyId := range keyIds {
+		if err := b.checkInLibraryKey(ctx, req.Storage, name, set, keyId); err != nil {
+			return nil, codedError(err)
+		}
+		checkedIn = append(checkedIn, keyId)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"check_ins": checkedIn,
+		},
+	}, nil
+}
+
+// libraryKeyRevoke checks a key in when its lease ends. Keys that were
+// already checked in are ignored.
+func (b *ccloudBackend) libraryKeyRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name, _ := req.Secret.InternalData["set"].(string)
+	keyId, _ := req.Secret.InternalData["key_id"].(string)
+	if name == "" || keyId == "" {
+		return nil, errors.New("secret is missing set or key_id internal data")
+	}
+
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	set, err := getLibrarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if set == nil {
+		return nil, nil
+	}
+
+	if i := set.findKey(keyId); i < 0 || !set.Keys[i].CheckedOut {
+		return nil, nil
+	}
+
+	return nil, b.checkInLibraryKey(ctx, req.Storage, name, set, keyId)
+}
+
+// libraryKeyRenew extends the lease of a check-out up to the set's max_ttl
+func (b *ccloudBackend) libraryKeyRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name, _ := req.Secret.InternalData["set"].(string)
+
+	set, err := getLibrarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if set == nil {
+		return nil, fmt.Errorf("library set %s no longer exists", name)
+	}
+
+	resp := &logical.Response{Secret: req.Secret}
+	resp.Secret.TTL = set.TTL
+	resp.Secret.MaxTTL = set.MaxTTL
+
+	return resp, nil
+}
+
+// checkInLibraryKey replaces a checked out key with a new one and deletes
+// it, so its secret stops working and is never handed out again. A key
+// checked in while the set is larger than its size is deleted without a
+// replacement. If any step fails, the key stays checked out so the check-in
+// can be retried. The caller must hold libraryLock.
+func (b *ccloudBackend) checkInLibraryKey(ctx context.Context, s logical.Storage, name string, set *librarySetEntry, keyId string) error {
+	client, err := b.getClient(ctx, s, set.Connection)
+	if err != nil {
+		return err
+	}
+
+	var replacement *libraryKey
+	if len(set.Keys) <= set.Size {
+		newKeyId, secret, err := b.createLibraryKey(ctx, client, name, set)
+		if err != nil {
+			return fmt.Errorf("error replacing key %s: %w", keyId, err)
+		}
+		replacement = &libraryKey{KeyId: newKeyId, Secret: secret, CreatedAt: time.Now()}
+	}
+
+	// rollback deletes the replacement if the check-in fails
+	rollback := func(err error) error {
+		if replacement != nil {
+			err = errors.Join(err, b.rollbackApiKey(ctx, client, replacement.KeyId))
+		}
+		return err
+	}
+
+	if err := client.DeleteApiKey(ctx, keyId); err != nil && !errors.Is(err, errCCloudNotFound) {
+		return rollback(err)
+	}
+
+	keys := make([]libraryKey, 0, len(set.Keys))
+	for _, key := range set.Keys {
+		switch {
+		case key.KeyId != keyId:
+			keys = append(keys, key)
+		case replacement != nil:
+			keys = append(keys, *replacement)
+		}
+	}
+	set.Keys = keys
+
+	if err := setLibrarySet(ctx, s, name, set); err != nil {
+		return rollback(err)
+	}
+
+	b.Logger().Info("checked in library key", "set", name, "key_id", keyId)
+
+	return nil
+}
+
+const (
+	pathLibraryCheckOutHelpSynopsis    = `Check out a key of a library set.`
+	pathLibraryCheckOutHelpDescription = `
+Hands out an available key of the set, exclusively to the requester, with a
+lease. The request fails with 429 if all keys are checked out. The key is
+checked in when the lease ends.
+`
+
+	pathLibraryCheckInHelpSynopsis    = `Check keys back into a library set.`
+	pathLibraryCheckInHelpDescription = `
+Checks in the given "key_ids", or all keys the requesting entity has checked
+out. Checked in keys are replaced by new keys and deleted, so their secrets
+stop working.
+`
+)
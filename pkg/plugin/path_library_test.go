@@ -0,0 +1,359 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const librarySetName = "batch-jobs"
+
+func testLibrarySetWrite(t *testing.T, b *ccloudBackend, s logical.Storage, data map[string]interface{}) (*logical.Response, error) {
+	t.Helper()
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      libraryStoragePrefix + librarySetName,
+		Data:      data,
+		Storage:   s,
+	})
+}
+
+func testLibraryCheckOut(t *testing.T, b *ccloudBackend, s logical.Storage, entityID string) (*logical.Response, error) {
+	t.Helper()
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      libraryStoragePrefix + librarySetName + "/check-out",
+		Storage:   s,
+		EntityID:  entityID,
+	})
+}
+
+func testLibraryStatus(t *testing.T, b *ccloudBackend, s logical.Storage) map[string]interface{} {
+	t.Helper()
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      libraryStoragePrefix + librarySetName + "/status",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+	return resp.Data
+}
+
+// ageLibrarySet makes the keys of the set old enough to be checked out
+func ageLibrarySet(t *testing.T, b *ccloudBackend, s logical.Storage) {
+	t.Helper()
+
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	set, err := getLibrarySet(context.Background(), s, librarySetName)
+	require.NoError(t, err)
+	for i := range set.Keys {
+		set.Keys[i].CreatedAt = time.Now().Add(-time.Hour)
+	}
+	require.NoError(t, setLibrarySet(context.Background(), s, librarySetName, set))
+}
+
+func TestLibraryChecksKeysOutExclusively(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	resp, err := testLibrarySetWrite(t, b, s, map[string]interface{}{
+		"key_kind":     "kafka",
+		"owner":        fakeRootKeyOwner,
+		"resource":     "lkc-1",
+		"resource_env": "env-1",
+		"size":         2,
+		"ttl":          "1h",
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+	assert.Equal(t, 3, fake.keyCount())
+	ageLibrarySet(t, b, s)
+
+	first, err := testLibraryCheckOut(t, b, s, "entity-1")
+	require.NoError(t, err)
+	require.NotNil(t, first.Secret)
+	second, err := testLibraryCheckOut(t, b, s, "entity-2")
+	require.NoError(t, err)
+	require.NotNil(t, second.Secret)
+
+	firstKeyId := first.Data["key_id"].(string)
+	assert.NotEqual(t, firstKeyId, second.Data["key_id"])
+	assert.Contains(t, first.Data["sasl.jaas.config"], firstKeyId)
+
+	status := testLibraryStatus(t, b, s)
+	assert.Equal(t, false, status[firstKeyId].(map[string]interface{})["available"])
+	assert.Equal(t, "entity-1", status[firstKeyId].(map[string]interface{})["entity_id"])
+
+	// all keys are checked out
+	_, err = testLibraryCheckOut(t, b, s, "entity-3")
+	require.Error(t, err)
+	var coded logical.HTTPCodedError
+	require.ErrorAs(t, err, &coded)
+	assert.Equal(t, http.StatusTooManyRequests, coded.Code())
+
+	// another entity cannot check the key in
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      libraryStoragePrefix + librarySetName + "/check-in",
+		Data:      map[string]interface{}{"key_ids": firstKeyId},
+		Storage:   s,
+		EntityID:  "entity-2",
+	})
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      libraryStoragePrefix + librarySetName + "/check-in",
+		Storage:   s,
+		EntityID:  "entity-1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{firstKeyId}, resp.Data["check_ins"])
+
+	// the checked in key was replaced
+	assert.False(t, fake.hasKey(firstKeyId))
+	assert.Equal(t, 3, fake.keyCount())
+	status = testLibraryStatus(t, b, s)
+	assert.Len(t, status, 2)
+	assert.NotContains(t, status, firstKeyId)
+
+	// revoking the lease of a key that was checked in has no effect
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    first.Secret,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, fake.keyCount())
+
+	// revoking a lease checks its key in
+	secondKeyId := second.Data["key_id"].(string)
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    second.Secret,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.False(t, fake.hasKey(secondKeyId))
+	for _, keyStatus := range testLibraryStatus(t, b, s) {
+		assert.Equal(t, true, keyStatus.(map[string]interface{})["available"])
+	}
+}
+
+func TestLibraryCheckInKeepsKeyWhenReplacementFails(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	_, err := testLibrarySetWrite(t, b, s, map[string]interface{}{
+		"key_kind": "cloud",
+		"owner":    fakeRootKeyOwner,
+		"size":     1,
+	})
+	require.NoError(t, err)
+	ageLibrarySet(t, b, s)
+
+	resp, err := testLibraryCheckOut(t, b, s, "entity-1")
+	require.NoError(t, err)
+	keyId := resp.Data["key_id"].(string)
+
+	fake.failNext(http.MethodPost, fakeFailure{status: http.StatusBadRequest})
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    resp.Secret,
+		Storage:   s,
+	})
+	require.Error(t, err)
+	assert.True(t, fake.hasKey(keyId))
+	assert.Equal(t, false, testLibraryStatus(t, b, s)[keyId].(map[string]interface{})["available"])
+
+	// Vault retries the revocation
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    resp.Secret,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.False(t, fake.hasKey(keyId))
+	assert.Equal(t, 2, fake.keyCount())
+}
+
+func TestLibrarySetResizeAndDelete(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	_, err := testLibrarySetWrite(t, b, s, map[string]interface{}{
+		"key_kind": "cloud",
+		"owner":    fakeRootKeyOwner,
+		"size":     3,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 4, fake.keyCount())
+	ageLibrarySet(t, b, s)
+
+	resp, err := testLibraryCheckOut(t, b, s, "entity-1")
+	require.NoError(t, err)
+	checkOut := resp.Secret
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      libraryStoragePrefix + librarySetName,
+		Data:      map[string]interface{}{"size": 1},
+		Storage:   s,
+	})
+	require.NoError(t, err)
+
+	// only the checked out key is left, and it is not replaced on check-in
+	assert.Equal(t, 2, fake.keyCount())
+	_, err = testLibraryCheckOut(t, b, s, "entity-2")
+	require.Error(t, err)
+
+	// a set with checked out keys cannot be deleted
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      libraryStoragePrefix + librarySetName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    checkOut,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, fake.keyCount())
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      libraryStoragePrefix + librarySetName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, fake.keyCount())
+}
+
+func TestLibraryChecksOutOnlyAgedKeys(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	_, err := testLibrarySetWrite(t, b, s, map[string]interface{}{
+		"key_kind": "cloud",
+		"owner":    fakeRootKeyOwner,
+		"size":     1,
+		"min_age":  "1h",
+	})
+	require.NoError(t, err)
+
+	// the new key is too young
+	_, err = testLibraryCheckOut(t, b, s, "entity-1")
+	require.Error(t, err)
+	var coded logical.HTTPCodedError
+	require.ErrorAs(t, err, &coded)
+	assert.Equal(t, http.StatusTooManyRequests, coded.Code())
+
+	ageLibrarySet(t, b, s)
+	resp, err := testLibraryCheckOut(t, b, s, "entity-1")
+	require.NoError(t, err)
+	keyId := resp.Data["key_id"].(string)
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    resp.Secret,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+
+	// the replacement of the checked in key ages before it is checked out
+	status := testLibraryStatus(t, b, s)
+	require.Len(t, status, 1)
+	for replacementId, keyStatus := range status {
+		assert.NotEqual(t, keyId, replacementId)
+		assert.Contains(t, keyStatus, "created_at")
+	}
+	_, err = testLibraryCheckOut(t, b, s, "entity-2")
+	require.ErrorAs(t, err, &coded)
+	assert.Equal(t, http.StatusTooManyRequests, coded.Code())
+
+	// keys stored without a creation time are old enough
+	set, err := getLibrarySet(context.Background(), s, librarySetName)
+	require.NoError(t, err)
+	set.Keys[0].CreatedAt = time.Time{}
+	require.NoError(t, setLibrarySet(context.Background(), s, librarySetName, set))
+
+	_, err = testLibraryCheckOut(t, b, s, "entity-2")
+	require.NoError(t, err)
+}
+
+func TestLibrarySetShrinkStoresKeysDeletedBeforeAFailure(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	_, err := testLibrarySetWrite(t, b, s, map[string]interface{}{
+		"key_kind": "cloud",
+		"owner":    fakeRootKeyOwner,
+		"size":     3,
+	})
+	require.NoError(t, err)
+	ageLibrarySet(t, b, s)
+	assert.Equal(t, 4, fake.keyCount())
+
+	fake.failNext(http.MethodDelete, fakeFailure{status: http.StatusBadRequest, skip: 1})
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      libraryStoragePrefix + librarySetName,
+		Data:      map[string]interface{}{"size": 1},
+		Storage:   s,
+	})
+	require.Error(t, err)
+
+	// the key deleted before the failure is no longer in the set
+	assert.Equal(t, 3, fake.keyCount())
+	status := testLibraryStatus(t, b, s)
+	require.Len(t, status, 2)
+	for keyId := range status {
+		assert.True(t, fake.hasKey(keyId))
+	}
+
+	// every key checked out exists
+	for i := range 2 {
+		resp, err := testLibraryCheckOut(t, b, s, fmt.Sprintf("entity-%d", i))
+		require.NoError(t, err)
+		assert.True(t, fake.hasKey(resp.Data["key_id"].(string)))
+	}
+}
+
+func TestLibrarySetWriteValidatesFields(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	for _, tc := range []struct {
+		data     map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"owner": fakeRootKeyOwner, "size": 1}, "missing key_kind"},
+		{map[string]interface{}{"key_kind": "cloud", "owner": fakeRootKeyOwner}, "missing size"},
+		{map[string]interface{}{"key_kind": "cloud", "owner": fakeRootKeyOwner, "size": 0}, "size must be at least 1"},
+		{map[string]interface{}{"key_kind": "cloud", "owner": "u-1", "size": 1}, "is not a service account"},
+		{map[string]interface{}{"key_kind": "cloud", "owner": fakeRootKeyOwner, "size": 1, "ttl": "2h", "max_ttl": "1h"}, "ttl cannot be greater"},
+	} {
+		resp, err := testLibrarySetWrite(t, b, s, tc.data)
+		require.NoError(t, err)
+		require.True(t, resp.IsError(), tc.expected)
+		assert.Contains(t, resp.Error().Error(), tc.expected)
+	}
+}
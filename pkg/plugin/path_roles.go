@@ -3,47 +3,283 @@ package plugin
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
 // apikeyRoleEntry defines the data required for a Vault role to access and
 // call the Confluent Cloud API Key endpoints
 type apikeyRoleEntry struct {
+	// Version is the storage version the role was written with
+	Version int `json:"version,omitempty"`
+
+	Connection string `json:"connection,omitempty"`
+
+	KeyKind string `json:"key_kind,omitempty"`
+
 	Owner    string `json:"owner"`
 	OwnerEnv string `json:"owner_env,omitempty"`
 
+	// DynamicServiceAccount creates a service account to own the key of
+	// each lease instead of using Owner
+	DynamicServiceAccount      bool   `json:"dynamic_service_account,omitempty"`
+	ServiceAccountNameTemplate string `json:"service_account_name_template,omitempty"`
+
+	// RoleBindings are bound to the owner of the key of each lease until the
+	// lease is revoked
+	RoleBindings []roleBinding `json:"role_bindings,omitempty"`
+
+	// ACLs are created for the owner of the key of each lease on the Kafka
+	// cluster of the role, through ACLRestEndpoint or the cluster's REST
+	// endpoint if it is not set, with the cluster's Kafka API key
+	// ACLApiKeyId, and deleted with the key the role has when the lease is
+	// revoked
+	ACLs            []kafkaACL `json:"acls,omitempty"`
+	ACLRestEndpoint string     `json:"acl_rest_endpoint,omitempty"`
+	ACLApiKeyId     string     `json:"acl_api_key_id,omitempty"`
+	ACLApiKeySecret string     `json:"acl_api_key_secret,omitempty"`
+
 	Resource    string `json:"resource,omitempty"`
 	ResourceEnv string `json:"resource_env,omitempty"`
 
 	TTL    time.Duration `json:"ttl,omitempty"`
 	MaxTTL time.Duration `json:"max_ttl,omitempty"`
 
-	MultiUseKey bool   `json:"multi_use_key"`
-	UsageCount  int    `json:"usage_count"`
-	CCKeyId     string `json:"cc_key_id"`
-	CCKeySecret string `json:"cc_key_secret"`
+	MultiUseKey bool `json:"multi_use_key"`
+
+	// PoolSize keys are created ahead of time and handed out once they are
+	// PoolMinAge old
+	PoolSize   int           `json:"pool_size,omitempty"`
+	PoolMinAge time.Duration `json:"pool_min_age,omitempty"`
+
+	// WaitForReady holds credential requests until the new key is accepted
+	// by its resource, for up to ReadyTimeout
+	WaitForReady bool          `json:"wait_for_ready,omitempty"`
+	ReadyTimeout time.Duration `json:"ready_timeout,omitempty"`
 
 	KeyDescription string `json:"key_description"`
+
+	DisplayNameTemplate string `json:"display_name_template,omitempty"`
+	DescriptionTemplate string `json:"description_template,omitempty"`
+
+	// SharedKeys are the keys handed out to the leases of a multi-use role,
+	// oldest first. New leases get the last one, until it is older than
+	// SharedKeyMaxAge; earlier ones are kept until their leases are revoked.
+	// They are managed by the backend and cannot be set through the role API.
+	SharedKeys      []sharedKeyState `json:"shared_keys,omitempty"`
+	SharedKeyMaxAge time.Duration    `json:"shared_key_max_age,omitempty"`
+
+	// Shared key state written by earlier versions of the plugin. It is
+	// moved to SharedKeys when the role is read.
+	LegacySharedKey   *sharedKeyState `json:"shared_key,omitempty"`
+	LegacyUsageCount  int             `json:"usage_count,omitempty"`
+	LegacyCCKeyId     string          `json:"cc_key_id,omitempty"`
+	LegacyCCKeySecret string          `json:"cc_key_secret,omitempty"`
+}
+
+// sharedKeyState tracks a key of a multi-use role and the leases that
+// currently use it
+type sharedKeyState struct {
+	KeyId  string `json:"key_id,omitempty"`
+	Secret string `json:"secret,omitempty"`
+
+	// Leases holds a reference to each lease that uses the key, so the
+	// usage count follows from them and revoking a lease again has no
+	// effect
+	Leases []string `json:"leases,omitempty"`
+
+	// UntrackedLeases counts the leases issued before leases were tracked
+	UntrackedLeases int `json:"usage_count,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// usageCount returns how many leases use the key
+func (k *sharedKeyState) usageCount() int {
+	return len(k.Leases) + k.UntrackedLeases
+}
+
+// internalRoleFields are role attributes managed by the backend. Earlier
+// versions accepted them on role writes; they are now rejected.
+var internalRoleFields = []string{"usage_count", "cc_key_id", "cc_key_secret"}
+
+// migrateLegacyState moves shared key state stored by earlier versions of
+// the plugin into SharedKeys. Their creation time is unknown, so a migrated
+// key is replaced on the next request once the role has a
+// shared_key_max_age. The migrated role is persisted on its next write.
+func (r *apikeyRoleEntry) migrateLegacyState() {
+	if len(r.SharedKeys) == 0 {
+		key := sharedKeyState{
+			KeyId:           r.LegacyCCKeyId,
+			Secret:          r.LegacyCCKeySecret,
+			UntrackedLeases: r.LegacyUsageCount,
+		}
+		if r.LegacySharedKey != nil {
+			key = *r.LegacySharedKey
+		}
+		if key.usageCount() > 0 {
+			r.SharedKeys = []sharedKeyState{key}
+		}
+	}
+
+	r.LegacySharedKey = nil
+	r.LegacyUsageCount = 0
+	r.LegacyCCKeyId = ""
+	r.LegacyCCKeySecret = ""
 }
 
-// toResponseData returns response data for a role
+// newestSharedKey returns the shared key created last, or nil if the role
+// has none
+func (r *apikeyRoleEntry) newestSharedKey() *sharedKeyState {
+	if len(r.SharedKeys) == 0 {
+		return nil
+	}
+	return &r.SharedKeys[len(r.SharedKeys)-1]
+}
+
+// currentSharedKey returns the shared key handed out to new leases, or nil
+// if the role has none or it is older than SharedKeyMaxAge
+func (r *apikeyRoleEntry) currentSharedKey(now time.Time) *sharedKeyState {
+	key := r.newestSharedKey()
+	if key == nil {
+		return nil
+	}
+	if r.SharedKeyMaxAge > 0 && now.Sub(key.CreatedAt) >= r.SharedKeyMaxAge {
+		return nil
+	}
+	return key
+}
+
+// releaseSharedKey ends the use of a shared key by a lease. Leases issued
+// before leases were tracked have no reference. It reports whether the key
+// is shared, and whether no lease uses it anymore, in which case it is
+// removed from the role.
+func (r *apikeyRoleEntry) releaseSharedKey(keyId, leaseRef string) (shared, unused bool) {
+	for i := range r.SharedKeys {
+		key := &r.SharedKeys[i]
+		if key.KeyId != keyId {
+			continue
+		}
+
+		switch j := slices.Index(key.Leases, leaseRef); {
+		case leaseRef != "" && j >= 0:
+			key.Leases = slices.Delete(key.Leases, j, j+1)
+		case leaseRef == "" && key.UntrackedLeases > 0:
+			key.UntrackedLeases--
+		default:
+			// released by an earlier attempt to revoke the lease
+			return true, false
+		}
+
+		if key.usageCount() > 0 {
+			return true, false
+		}
+
+		r.SharedKeys = slices.Delete(r.SharedKeys, i, i+1)
+		return true, true
+	}
+
+	return false, true
+}
+
+// displayNameTemplate returns the template for the display names of the
+// role's keys
+func (r *apikeyRoleEntry) displayNameTemplate() string {
+	if r.DisplayNameTemplate != "" {
+		return r.DisplayNameTemplate
+	}
+	return defaultDisplayNameTemplate
+}
+
+// serviceAccountNameTemplate returns the template for the names of the
+// service accounts created for the role's leases
+func (r *apikeyRoleEntry) serviceAccountNameTemplate() string {
+	if r.ServiceAccountNameTemplate != "" {
+		return r.ServiceAccountNameTemplate
+	}
+	return defaultServiceAccountNameTemplate
+}
+
+// toResponseData returns response data for a role. The state of the shared
+// multi-use keys is read-only, and their secrets are never returned, only
+// whether the newest one's is set.
 func (r *apikeyRoleEntry) toResponseData() map[string]interface{} {
 	respData := map[string]interface{}{
-		"owner":         r.Owner,
-		"owner_env":     r.OwnerEnv,
-		"resource":      r.Resource,
-		"resource_env":  r.ResourceEnv,
-		"ttl":           r.TTL.Seconds(),
-		"max_ttl":       r.MaxTTL.Seconds(),
-		"multi_use_key": r.MultiUseKey,
-		"usage_count":   r.UsageCount,
-		"cc_key_id":     r.CCKeyId,
-		"cc_key_secret": r.CCKeySecret,
-		"description":   r.KeyDescription,
+		"connection":        normalizeConnection(r.Connection),
+		"key_kind":          r.keyKind(),
+		"owner":             r.Owner,
+		"owner_env":         r.OwnerEnv,
+		"resource":          r.Resource,
+		"resource_env":      r.ResourceEnv,
+		"ttl":               r.TTL.Seconds(),
+		"max_ttl":           r.MaxTTL.Seconds(),
+		"multi_use_key":     r.MultiUseKey,
+		"usage_count":       0,
+		"cc_key_id":         "",
+		"cc_key_secret_set": false,
+		"description":       r.KeyDescription,
+
+		"display_name_template": r.displayNameTemplate(),
+		"description_template":  r.DescriptionTemplate,
+
+		"dynamic_service_account":       r.DynamicServiceAccount,
+		"service_account_name_template": r.serviceAccountNameTemplate(),
+	}
+
+	roleBindings := make([]map[string]interface{}, 0, len(r.RoleBindings))
+	for _, binding := range r.RoleBindings {
+		roleBindings = append(roleBindings, map[string]interface{}{
+			"role_name":   binding.RoleName,
+			"crn_pattern": binding.CRNPattern,
+		})
+	}
+	respData["role_bindings"] = roleBindings
+
+	acls := make([]map[string]interface{}, 0, len(r.ACLs))
+	for _, acl := range r.ACLs {
+		acls = append(acls, map[string]interface{}{
+			"resource_type": acl.ResourceType,
+			"resource_name": acl.ResourceName,
+			"pattern_type":  acl.PatternType,
+			"operation":     acl.Operation,
+			"permission":    acl.Permission,
+			"host":          acl.Host,
+		})
+	}
+	respData["acls"] = acls
+	respData["acl_rest_endpoint"] = r.ACLRestEndpoint
+	respData["acl_api_key_id"] = r.ACLApiKeyId
+	respData["acl_api_key_secret_set"] = r.ACLApiKeySecret != ""
+	respData["pool_size"] = r.PoolSize
+	respData["pool_min_age"] = int64(r.poolMinAge().Seconds())
+	if key := r.newestSharedKey(); key != nil {
+		respData["usage_count"] = key.usageCount()
+		respData["cc_key_id"] = key.KeyId
+		respData["cc_key_secret_set"] = key.Secret != ""
+	}
+
+	sharedKeys := make([]map[string]interface{}, 0, len(r.SharedKeys))
+	for _, key := range r.SharedKeys {
+		createdAt := ""
+		if !key.CreatedAt.IsZero() {
+			createdAt = key.CreatedAt.Format(time.RFC3339)
+		}
+		sharedKeys = append(sharedKeys, map[string]interface{}{
+			"key_id":      key.KeyId,
+			"usage_count": key.usageCount(),
+			"created_at":  createdAt,
+		})
 	}
+	respData["shared_keys"] = sharedKeys
+	respData["shared_key_max_age"] = int64(r.SharedKeyMaxAge.Seconds())
+	respData["wait_for_ready"] = r.WaitForReady
+	respData["ready_timeout"] = int64(r.readyTimeout().Seconds())
+
 	return respData
 }
 
@@ -62,18 +298,57 @@ func pathRole(b *ccloudBackend) []*framework.Path {
 					Description: "Name of the role",
 					Required:    true,
 				},
+				"connection": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the connection used to manage the role's API keys. If not set, the default connection is used.",
+				},
+				"key_kind": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Kind of API key to generate: cloud, kafka, schema_registry, ksqldb or flink. If not set, owner_env, resource and resource_env are required and the kind follows from the resource.",
+				},
 				"owner": {
 					Type:        framework.TypeString,
 					Description: "Confluent Cloud ID of the User or ServiceAccount which will own the API key.",
 					Required:    true,
 				},
+				"dynamic_service_account": {
+					Type:        framework.TypeBool,
+					Description: "Create a service account to own the key of each lease, and delete it when the lease is revoked. Cannot be combined with owner or multi_use_key.",
+				},
+				"service_account_name_template": {
+					Type:        framework.TypeString,
+					Description: "Template for the names of service accounts created with dynamic_service_account. Defaults to " + defaultServiceAccountNameTemplate,
+				},
+				"role_bindings": {
+					Type:        framework.TypeSlice,
+					Description: "RBAC role bindings to create on the key owner for the lifetime of each lease, as objects with a role_name and crn_pattern, or strings of the form <role_name>=<crn_pattern>. Requires dynamic_service_account.",
+				},
+				"acls": {
+					Type:        framework.TypeSlice,
+					Description: "Kafka ACLs to create for the key owner for the lifetime of each lease, as objects with a resource_type, resource_name, pattern_type (default LITERAL), operation, permission (default ALLOW) and host (default *). Requires kafka keys and dynamic_service_account.",
+				},
+				"acl_rest_endpoint": {
+					Type:        framework.TypeString,
+					Description: "REST endpoint of the Kafka cluster to create ACLs through. Looked up from Confluent Cloud if not set.",
+				},
+				"acl_api_key_id": {
+					Type:        framework.TypeString,
+					Description: "ID of a Kafka API key of the cluster that may manage its ACLs. Required with acls.",
+				},
+				"acl_api_key_secret": {
+					Type:        framework.TypeString,
+					Description: "Secret of the Kafka API key used to manage ACLs.",
+					DisplayAttrs: &framework.DisplayAttributes{
+						Sensitive: true,
+					},
+				},
 				"owner_env": {
 					Type:        framework.TypeString,
 					Description: "The owner's CCloud Environment ID, if env-scoped.",
 				},
 				"resource": {
 					Type:        framework.TypeString,
-					Description: "Confluent Cloud ID of the Cluster for which the key will be created. If not specified, a Cloud API Key will be generated, instead.",
+					Description: "Confluent Cloud ID of the cluster, or the Flink region, for which the key will be created. Not used for Cloud API keys.",
 				},
 				"resource_env": {
 					Type:        framework.TypeString,
@@ -91,23 +366,44 @@ func pathRole(b *ccloudBackend) []*framework.Path {
 					Type:        framework.TypeString,
 					Description: "Description of the key (will be visible in CC's UI",
 				},
+				"display_name_template": {
+					Type:        framework.TypeString,
+					Description: "Template for the display name of generated keys. Defaults to " + defaultDisplayNameTemplate,
+				},
+				"description_template": {
+					Type:        framework.TypeString,
+					Description: "Template for the description of generated keys. Takes precedence over key_description.",
+				},
+				"validate": {
+					Type:          framework.TypeString,
+					Default:       roleValidationNone,
+					AllowedValues: []interface{}{roleValidationNone, roleValidationWarn, roleValidationError},
+					Description:   "Check the owner and resource against Confluent Cloud before storing the role. With \"warn\", problems are returned as warnings; with \"error\", they fail the write. Not stored with the role.",
+				},
+				"pool_size": {
+					Type:        framework.TypeInt,
+					Description: "Number of keys to create ahead of time, so credentials are handed out with keys that brokers already accept. Cannot be combined with multi_use_key or dynamic_service_account.",
+				},
+				"pool_min_age": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How long pooled keys age before they are handed out. Defaults to 60 seconds.",
+				},
+				"wait_for_ready": {
+					Type:        framework.TypeBool,
+					Description: "Return credentials only once the new key is accepted by its resource. Not supported for flink keys.",
+				},
+				"ready_timeout": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How long credential requests wait for a new key to be accepted when wait_for_ready is set. Defaults to 120 seconds.",
+				},
 				"multi_use_key": {
 					Type:        framework.TypeBool,
 					Default:     false,
 					Description: "Boolean to indicate if a role is multi use or single use. If the role is not set then assume it is single usage.",
 				},
-				"usage_count": {
-					Type:        framework.TypeInt,
-					Default:     0,
-					Description: "Count to keep track of role usage",
-				},
-				"cc_key_id": {
-					Type:        framework.TypeString,
-					Description: "Key ID for confluent cloud",
-				},
-				"cc_key_secret": {
-					Type:        framework.TypeString,
-					Description: "Key secret for confluent cloud",
+				"shared_key_max_age": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How long the shared key of a multi-use role is handed out before new leases get a new key. Earlier keys stay valid until their leases are revoked. Defaults to no limit.",
 				},
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
@@ -174,6 +470,17 @@ func (confluentCloudBackend *ccloudBackend) pathRolesWrite(ctx context.Context,
 		return logical.ErrorResponse("missing role name"), nil
 	}
 
+	for _, field := range internalRoleFields {
+		if _, ok := req.Data[field]; ok {
+			return logical.ErrorResponse("%s is managed by the backend and cannot be set", field), nil
+		}
+	}
+
+	// the shared keys of the role are kept as they are while it is written
+	lock := locksutil.LockForKey(confluentCloudBackend.roleLocks, name.(string))
+	lock.Lock()
+	defer lock.Unlock()
+
 	roleEntry, err := confluentCloudBackend.getRole(ctx, req.Storage, name.(string))
 	if err != nil {
 		return nil, err
@@ -185,30 +492,52 @@ func (confluentCloudBackend *ccloudBackend) pathRolesWrite(ctx context.Context,
 
 	createOperation := (req.Operation == logical.CreateOperation)
 
+	if connection, ok := d.GetOk("connection"); ok {
+		roleEntry.Connection = normalizeConnection(connection.(string))
+	}
+
+	if keyKind, ok := d.GetOk("key_kind"); ok {
+		roleEntry.KeyKind = keyKind.(string)
+	}
+
+	// roles without a key kind keep requiring all fields, as before kinds
+	// were introduced
+	fieldsRequired := createOperation && roleEntry.KeyKind == ""
+
+	if dynamic, ok := d.GetOk("dynamic_service_account"); ok {
+		roleEntry.DynamicServiceAccount = dynamic.(bool)
+	}
+
 	if owner, ok := d.GetOk("owner"); ok {
 		roleEntry.Owner = owner.(string)
-	} else if !ok && createOperation {
+	} else if !ok && createOperation && !roleEntry.DynamicServiceAccount {
 		return nil, fmt.Errorf("missing owner in role")
 	}
 
 	if ownerEnv, ok := d.GetOk("owner_env"); ok {
 		roleEntry.OwnerEnv = ownerEnv.(string)
-	} else if !ok && createOperation {
+	} else if !ok && fieldsRequired && !roleEntry.DynamicServiceAccount {
 		return nil, fmt.Errorf("missing owner_env in role")
 	}
 
 	if resource, ok := d.GetOk("resource"); ok {
 		roleEntry.Resource = resource.(string)
-	} else if !ok && createOperation {
+	} else if !ok && fieldsRequired {
 		return nil, fmt.Errorf("missing resource in role")
 	}
 
 	if resourceEnv, ok := d.GetOk("resource_env"); ok {
 		roleEntry.ResourceEnv = resourceEnv.(string)
-	} else if !ok && createOperation {
+	} else if !ok && fieldsRequired {
 		return nil, fmt.Errorf("missing resource_env in role")
 	}
 
+	if roleEntry.KeyKind != "" {
+		if err := roleEntry.validateKeyKind(); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
 	if ttlRaw, ok := d.GetOk("ttl"); ok {
 		roleEntry.TTL = time.Duration(ttlRaw.(int)) * time.Second
 	} else if createOperation {
@@ -231,23 +560,157 @@ func (confluentCloudBackend *ccloudBackend) pathRolesWrite(ctx context.Context,
 		roleEntry.MultiUseKey = false
 	}
 
-	if ccKeyId, ok := d.GetOk("cc_key_id"); ok {
-		roleEntry.CCKeyId = ccKeyId.(string)
+	if sharedKeyMaxAge, ok := d.GetOk("shared_key_max_age"); ok {
+		roleEntry.SharedKeyMaxAge = time.Duration(sharedKeyMaxAge.(int)) * time.Second
+	}
+	if roleEntry.SharedKeyMaxAge > 0 && !roleEntry.MultiUseKey {
+		return logical.ErrorResponse("shared_key_max_age requires multi_use_key"), nil
+	}
+
+	if roleEntry.DynamicServiceAccount {
+		if roleEntry.Owner != "" || roleEntry.OwnerEnv != "" {
+			return logical.ErrorResponse("owner and owner_env cannot be set with dynamic_service_account"), nil
+		}
+		if roleEntry.MultiUseKey {
+			return logical.ErrorResponse("multi_use_key cannot be combined with dynamic_service_account"), nil
+		}
+	}
+
+	if rawBindings, ok := d.GetOk("role_bindings"); ok {
+		roleBindings, err := parseRoleBindings(rawBindings.([]interface{}))
+		if err != nil {
+			return logical.ErrorResponse("invalid role_bindings: %s", err), nil
+		}
+		roleEntry.RoleBindings = roleBindings
+	}
+	// bindings are identified by their fields, so the leases of a role with a
+	// fixed owner would share and revoke each other's bindings
+	if len(roleEntry.RoleBindings) > 0 && !roleEntry.DynamicServiceAccount {
+		return logical.ErrorResponse("role_bindings require dynamic_service_account"), nil
+	}
+
+	if rawACLs, ok := d.GetOk("acls"); ok {
+		acls, err := parseKafkaACLs(rawACLs.([]interface{}))
+		if err != nil {
+			return logical.ErrorResponse("invalid acls: %s", err), nil
+		}
+		roleEntry.ACLs = acls
+	}
+	if aclRestEndpoint, ok := d.GetOk("acl_rest_endpoint"); ok {
+		roleEntry.ACLRestEndpoint = aclRestEndpoint.(string)
+	}
+	if aclApiKeyId, ok := d.GetOk("acl_api_key_id"); ok {
+		roleEntry.ACLApiKeyId = aclApiKeyId.(string)
+	}
+	if aclApiKeySecret, ok := d.GetOk("acl_api_key_secret"); ok {
+		roleEntry.ACLApiKeySecret = aclApiKeySecret.(string)
+	}
+	if len(roleEntry.ACLs) > 0 {
+		// ACLs are identified by their fields, so the leases of a role with
+		// a fixed owner would share and revoke each other's ACLs
+		if !roleEntry.DynamicServiceAccount {
+			return logical.ErrorResponse("acls require dynamic_service_account"), nil
+		}
+		if roleEntry.keyKind() != keyKindKafka {
+			return logical.ErrorResponse("acls require kafka keys"), nil
+		}
+		// Kafka REST only accepts the cluster's own API keys
+		if roleEntry.ACLApiKeyId == "" || roleEntry.ACLApiKeySecret == "" {
+			return logical.ErrorResponse("acls require acl_api_key_id and acl_api_key_secret"), nil
+		}
+	}
+
+	if poolSize, ok := d.GetOk("pool_size"); ok {
+		roleEntry.PoolSize = poolSize.(int)
+	}
+	if poolMinAge, ok := d.GetOk("pool_min_age"); ok {
+		roleEntry.PoolMinAge = time.Duration(poolMinAge.(int)) * time.Second
+	}
+	if roleEntry.PoolSize < 0 || roleEntry.PoolMinAge < 0 {
+		return logical.ErrorResponse("pool_size and pool_min_age cannot be negative"), nil
+	}
+	if roleEntry.PoolSize > 0 && (roleEntry.MultiUseKey || roleEntry.DynamicServiceAccount) {
+		return logical.ErrorResponse("pool_size cannot be combined with multi_use_key or dynamic_service_account"), nil
+	}
+
+	if waitForReady, ok := d.GetOk("wait_for_ready"); ok {
+		roleEntry.WaitForReady = waitForReady.(bool)
+	}
+	if readyTimeout, ok := d.GetOk("ready_timeout"); ok {
+		roleEntry.ReadyTimeout = time.Duration(readyTimeout.(int)) * time.Second
 	}
-	if ccKeySecret, ok := d.GetOk("cc_key_secret"); ok {
-		roleEntry.CCKeySecret = ccKeySecret.(string)
+	if roleEntry.WaitForReady && roleEntry.keyKind() == keyKindFlink {
+		return logical.ErrorResponse("wait_for_ready is not supported for flink keys"), nil
+	}
+
+	if nameTemplate, ok := d.GetOk("service_account_name_template"); ok {
+		roleEntry.ServiceAccountNameTemplate = nameTemplate.(string)
+	}
+	if err := validateKeyTemplate(roleEntry.serviceAccountNameTemplate()); err != nil {
+		return logical.ErrorResponse("invalid service_account_name_template: %s", err), nil
 	}
 
 	if ccKeyDescription, ok := d.GetOk("key_description"); ok {
 		roleEntry.KeyDescription = ccKeyDescription.(string)
 	}
 
+	if displayNameTemplate, ok := d.GetOk("display_name_template"); ok {
+		roleEntry.DisplayNameTemplate = displayNameTemplate.(string)
+	}
+	if err := validateKeyTemplate(roleEntry.displayNameTemplate()); err != nil {
+		return logical.ErrorResponse("invalid display_name_template: %s", err), nil
+	}
+
+	if descriptionTemplate, ok := d.GetOk("description_template"); ok {
+		roleEntry.DescriptionTemplate = descriptionTemplate.(string)
+	}
+	if roleEntry.DescriptionTemplate != "" {
+		if err := validateKeyTemplate(roleEntry.DescriptionTemplate); err != nil {
+			return logical.ErrorResponse("invalid description_template: %s", err), nil
+		}
+	}
+
+	validation := roleValidationNone
+	if validate, ok := d.GetOk("validate"); ok {
+		validation = validate.(string)
+	}
+
+	var warnings []string
+	switch validation {
+	case roleValidationNone:
+	case roleValidationWarn, roleValidationError:
+		problems := confluentCloudBackend.validateRole(ctx, req.Storage, roleEntry)
+		if len(problems) > 0 && validation == roleValidationError {
+			return logical.ErrorResponse("role validation failed: %s", strings.Join(problems, "; ")), nil
+		}
+		warnings = problems
+	default:
+		return logical.ErrorResponse("validate must be one of %q, %q or %q", roleValidationNone, roleValidationWarn, roleValidationError), nil
+	}
+
 	confluentCloudBackend.Logger().Info("pathRolesWrite")
 
 	if err := setRole(ctx, req.Storage, name.(string), roleEntry); err != nil {
 		return nil, err
 	}
 
+	// pooled keys created with the previous settings are deleted now, and
+	// the pool is refilled in the background
+	if _, _, err := confluentCloudBackend.preparePool(ctx, req.Storage, name.(string)); err != nil {
+		warnings = append(warnings, fmt.Sprintf("error deleting pooled keys, they are retried in the background: %s", err))
+	}
+	if roleEntry.PoolSize > 0 {
+		confluentCloudBackend.schedulePoolRefill(name.(string))
+	}
+
+	if len(warnings) > 0 {
+		resp := &logical.Response{}
+		for _, warning := range warnings {
+			resp.AddWarning(warning)
+		}
+		return resp, nil
+	}
+
 	return nil, nil
 }
 
@@ -263,16 +726,47 @@ func (b *ccloudBackend) pathRoleExistenceCheck(ctx context.Context, req *logical
 
 // pathRolesDelete makes a request to Vault storage to delete a role
 func (confluentCloudBackend *ccloudBackend) pathRolesDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	err := req.Storage.Delete(ctx, "role/"+d.Get("name").(string))
+	name := d.Get("name").(string)
+
+	lock := locksutil.LockForKey(confluentCloudBackend.roleLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := confluentCloudBackend.getRole(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// the leases of shared keys are tracked on the role, so without it the
+	// first revocation would delete a key that other leases still use
+	if role != nil {
+		leases := 0
+		for _, key := range role.SharedKeys {
+			leases += key.usageCount()
+		}
+		if leases > 0 {
+			return logical.ErrorResponse("role %s has shared keys in use by %d leases; revoke them before deleting the role", name, leases), nil
+		}
+	}
+
+	err = req.Storage.Delete(ctx, "role/"+name)
 	if err != nil {
 		return nil, fmt.Errorf("error deleting apikey role: %w", err)
 	}
 
+	// pooled keys that cannot be deleted now are retried in the background
+	if err := confluentCloudBackend.drainPool(ctx, req.Storage, name); err != nil {
+		resp := &logical.Response{}
+		resp.AddWarning(fmt.Sprintf("error deleting pooled keys, they are retried in the background: %s", err))
+		return resp, nil
+	}
+
 	return nil, nil
 }
 
 // setRole adds the role to the Vault storage API
 func setRole(ctx context.Context, s logical.Storage, name string, roleEntry *apikeyRoleEntry) error {
+	roleEntry.Version = roleStorageVersion
 	entry, err := logical.StorageEntryJSON("role/"+name, roleEntry)
 	if err != nil {
 		return err
@@ -309,6 +803,11 @@ func (confluentCloudBackend *ccloudBackend) getRole(ctx context.Context, s logic
 	if err := entry.DecodeJSON(&role); err != nil {
 		return nil, err
 	}
+	if role.Version > roleStorageVersion {
+		return nil, fmt.Errorf("role %s was written by a newer version of the plugin (storage version %d)", name, role.Version)
+	}
+	role.migrateLegacyState()
+
 	return &role, nil
 }
 
@@ -317,6 +816,72 @@ const (
 	pathRoleHelpDescription = `
 This path allows you to read and write roles used to generate Confluent Cloud
 Cluster API keys.
+
+The "usage_count", "cc_key_id" and "cc_key_secret_set" attributes of a
+multi-use role report the state of its newest shared key, and "shared_keys"
+lists every shared key that leases still use. They are managed by the backend
+and cannot be written.
+
+A multi-use role hands out the same key until all of its leases are revoked,
+which on a busy role may never happen. With "shared_key_max_age", once the
+shared key is older than that, new leases get a newly created key while the
+earlier key stays valid until its own leases are revoked.
+A multi-use role cannot be deleted while leases use its shared keys.
+
+The display name and description of generated keys are rendered from
+"display_name_template" and "description_template". Templates can refer to
+{{.RoleName}}, {{.MountPoint}}, {{.EntityID}}, {{.EntityName}},
+{{.DisplayName}} (the display name of the requesting token), {{.RequestTime}}
+and {{.RandomSuffix}}, and use the functions of Vault's username templates,
+such as "truncate" and "lowercase". Templates are checked when the role is
+written.
+
+With "dynamic_service_account", every lease gets its own service account,
+named from "service_account_name_template", which owns the lease's key. Both
+are deleted when the lease is revoked, so every workload appears as its own
+principal in Confluent Cloud audit logs.
+
+"role_bindings" lists RBAC roles, such as DeveloperRead on a topic prefix, that
+are bound to the key owner when a lease is created and unbound when it is
+revoked, so leases get only the access their role grants. Bindings require
+"dynamic_service_account", so that leases do not share them.
+
+For clusters that use ACLs rather than RBAC, "acls" lists Kafka ACLs that are
+created for the key owner through the cluster's Kafka REST v3 API when a lease
+is created, and deleted when it is revoked. The REST endpoint is looked up
+from Confluent Cloud unless "acl_rest_endpoint" is set. Kafka REST accepts
+only Kafka API keys of the cluster, so "acl_api_key_id" and
+"acl_api_key_secret" name a key of the cluster whose owner may manage ACLs,
+such as one owned by a CloudClusterAdmin. Leases are revoked with the key the
+role has at that time, so the key can be rotated on the role, but a role must
+keep a key while leases with ACLs are outstanding. ACLs require
+"dynamic_service_account", so that leases do not share them.
+
+Newly created keys take a while before brokers accept them. With "pool_size",
+a background worker keeps that many keys ready for the role, and credentials
+are handed out with a pooled key once it is "pool_min_age" old; otherwise a
+key is created as usual. Pooled keys are named without the details of the
+request that receives them. When the role's owner, resource, connection or
+key naming changes, or the role is deleted, keys that were not handed out are
+deleted.
+
+With "wait_for_ready", credential requests return only once the new key is
+accepted: the backend repeatedly calls an authenticated endpoint of the key's
+resource with the key, such as the cluster metadata of Kafka REST, the subjects
+of Schema Registry, or the key itself for Cloud keys. If the key is not
+accepted within "ready_timeout", the request fails with 504 and everything
+created for the lease is deleted.
+
+"key_kind" selects the kind of API key: "cloud" keys are not scoped to a
+resource, while "kafka", "schema_registry", "ksqldb" and "flink" keys require
+"resource" and "resource_env". Credentials include client settings for their
+kind, such as a JAAS config for Kafka or basic auth user info for Schema
+Registry and ksqlDB.
+
+Set "validate" to "warn" or "error" on a write to check that the owner is an
+existing service account or user and that the resource is an existing Kafka,
+Schema Registry or ksqlDB cluster in "resource_env". Problems are returned as warnings,
+or fail the write with "error".
 `
 
 	pathRoleListHelpSynopsis    = `List the existing roles in CCloud backend`
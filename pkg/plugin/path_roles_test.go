@@ -512,3 +512,337 @@ func testTokenRoleDelete(testingT *testing.T, confluentCloudBackend *ccloudBacke
 		Storage:   logicalStorage,
 	})
 }
+
+func TestRoleReadDoesNotReturnSharedKeySecret(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"owner":         owner,
+		"owner_env":     owner_env,
+		"resource":      resource,
+		"resource_env":  resource_env,
+		"multi_use_key": true,
+	})
+	require.NoError(t, err)
+
+	creds, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + roleName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	secret := creds.Data["secret"].(string)
+
+	resp, err := testTokenRoleRead(t, b, s)
+	require.NoError(t, err)
+	requireNoSecrets(t, resp, secret)
+	assert.Equal(t, creds.Data["key_id"], resp.Data["cc_key_id"])
+	assert.Equal(t, true, resp.Data["cc_key_secret_set"])
+}
+
+func TestRoleWriteRejectsInternalFields(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	for _, field := range internalRoleFields {
+		resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+			"owner":        owner,
+			"owner_env":    owner_env,
+			"resource":     resource,
+			"resource_env": resource_env,
+			field:          "injected",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError(), field)
+		assert.Contains(t, resp.Error().Error(), field)
+	}
+
+	role, err := b.getRole(context.Background(), s, roleName)
+	require.NoError(t, err)
+	assert.Nil(t, role)
+}
+
+func TestRoleWriteValidatesKeyTemplates(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	for field, tmpl := range map[string]string{
+		"display_name_template": "{{.RoleName",
+		"description_template":  "{{.Unknown}}",
+	} {
+		resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+			"owner":        owner,
+			"owner_env":    owner_env,
+			"resource":     resource,
+			"resource_env": resource_env,
+			field:          tmpl,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError(), field)
+		assert.Contains(t, resp.Error().Error(), "invalid "+field)
+	}
+
+	resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"owner":                 owner,
+		"owner_env":             owner_env,
+		"resource":              resource,
+		"resource_env":          resource_env,
+		"display_name_template": "{{.RoleName}}-{{.EntityName | truncate 8}}",
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	resp, err = testTokenRoleRead(t, b, s)
+	require.NoError(t, err)
+	assert.Equal(t, "{{.RoleName}}-{{.EntityName | truncate 8}}", resp.Data["display_name_template"])
+	assert.Equal(t, "", resp.Data["description_template"])
+}
+
+func TestRoleWriteValidatesAgainstConfluentCloud(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+	fake.addObject(kafkaClustersPath+"/lkc-1", "env-1")
+
+	writeRole := func(validate, owner, resource, resourceEnv string) *logical.Response {
+		resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+			"owner":        owner,
+			"owner_env":    "env-1",
+			"resource":     resource,
+			"resource_env": resourceEnv,
+			"validate":     validate,
+		})
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := writeRole(roleValidationError, fakeRootKeyOwner, "lkc-1", "env-1")
+	require.Nil(t, resp)
+
+	resp = writeRole(roleValidationError, "sa-missing", "lkc-1", "env-1")
+	require.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), `owner "sa-missing" does not exist`)
+
+	resp = writeRole(roleValidationError, fakeRootKeyOwner, "lkc-1", "env-2")
+	require.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), `resource "lkc-1" does not exist in environment "env-2"`)
+
+	resp = writeRole(roleValidationWarn, "roleOwner", "lkc-2", "env-1")
+	require.NotNil(t, resp)
+	require.False(t, resp.IsError())
+	require.Len(t, resp.Warnings, 2)
+	assert.Contains(t, resp.Warnings[0], `owner "roleOwner" is neither a service account`)
+	assert.Contains(t, resp.Warnings[1], `resource "lkc-2" does not exist`)
+
+	role, err := b.getRole(context.Background(), s, roleName)
+	require.NoError(t, err)
+	assert.Equal(t, "roleOwner", role.Owner, "roles are stored despite warnings")
+
+	resp = writeRole("sometimes", fakeRootKeyOwner, "lkc-1", "env-1")
+	require.True(t, resp.IsError())
+}
+
+func TestRoleWriteValidatesKeyKind(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	for _, tc := range []struct {
+		data     map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"key_kind": "kafka"}, "resource is required for kafka keys"},
+		{map[string]interface{}{"key_kind": "kafka", "resource": "lkc-1"}, "resource_env is required for kafka keys"},
+		{map[string]interface{}{"key_kind": "cloud", "resource": "lkc-1"}, "cannot be set for cloud keys"},
+		{map[string]interface{}{"key_kind": "schema_registry", "resource": "lkc-1", "resource_env": "env-1"}, "must be a Schema Registry cluster"},
+		{map[string]interface{}{"key_kind": "kafka_streams"}, "key_kind must be one of"},
+	} {
+		tc.data["owner"] = owner
+		resp, err := testTokenRoleCreate(t, b, s, roleName, tc.data)
+		require.NoError(t, err)
+		require.True(t, resp.IsError(), tc.expected)
+		assert.Contains(t, resp.Error().Error(), tc.expected)
+	}
+
+	// cloud keys need neither a resource nor environments
+	resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"key_kind": "cloud",
+		"owner":    owner,
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	resp, err = testTokenRoleRead(t, b, s)
+	require.NoError(t, err)
+	assert.Equal(t, keyKindCloud, resp.Data["key_kind"])
+}
+
+func TestRoleWriteValidatesDynamicServiceAccount(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	for _, tc := range []struct {
+		data     map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"owner": owner}, "owner and owner_env cannot be set"},
+		{map[string]interface{}{"multi_use_key": true}, "multi_use_key cannot be combined"},
+		{map[string]interface{}{"service_account_name_template": "{{.Missing}}"}, "service_account_name_template"},
+	} {
+		tc.data["key_kind"] = "cloud"
+		tc.data["dynamic_service_account"] = true
+		resp, err := testTokenRoleCreate(t, b, s, roleName, tc.data)
+		require.NoError(t, err)
+		require.True(t, resp.IsError(), tc.expected)
+		assert.Contains(t, resp.Error().Error(), tc.expected)
+	}
+}
+
+func TestRoleWriteValidatesRoleBindings(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	for _, tc := range []struct {
+		data     map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"role_bindings": []interface{}{"DeveloperRead"}}, "crn_pattern must be a CRN"},
+		{map[string]interface{}{"role_bindings": []interface{}{"=crn://confluent.cloud/organization=o-1"}}, "role_name is required"},
+		{map[string]interface{}{"role_bindings": []interface{}{42}}, "must be an object or a string"},
+		{map[string]interface{}{"role_bindings": []interface{}{"DeveloperRead=crn://confluent.cloud/organization=o-1"}, "dynamic_service_account": false, "owner": owner}, "role_bindings require dynamic_service_account"},
+	} {
+		tc.data["key_kind"] = "cloud"
+		if _, ok := tc.data["dynamic_service_account"]; !ok {
+			tc.data["dynamic_service_account"] = true
+		}
+		resp, err := testTokenRoleCreate(t, b, s, roleName, tc.data)
+		require.NoError(t, err)
+		require.True(t, resp.IsError(), tc.expected)
+		assert.Contains(t, resp.Error().Error(), tc.expected)
+	}
+
+	resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"key_kind":                "cloud",
+		"dynamic_service_account": true,
+		"role_bindings":           []interface{}{"DeveloperRead=crn://confluent.cloud/organization=o-1"},
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	resp, err = testTokenRoleRead(t, b, s)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{
+		{"role_name": "DeveloperRead", "crn_pattern": "crn://confluent.cloud/organization=o-1"},
+	}, resp.Data["role_bindings"])
+}
+
+func TestRoleWriteValidatesKafkaACLs(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	read := map[string]interface{}{"resource_type": "TOPIC", "resource_name": "orders", "operation": "READ"}
+	for _, tc := range []struct {
+		data     map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"acls": []interface{}{"TOPIC:orders:READ"}}, "must be an object"},
+		{map[string]interface{}{"acls": []interface{}{map[string]interface{}{"resource_type": "TOPIC", "operation": "READ"}}}, "resource_name is required"},
+		{map[string]interface{}{"acls": []interface{}{map[string]interface{}{"resource_type": "TOPIC", "resource_name": "orders", "operation": "SUBSCRIBE"}}}, "operation must be one of"},
+		{map[string]interface{}{"acls": []interface{}{map[string]interface{}{"resource_type": "CLUSTER", "resource_name": "lkc-1", "operation": "CREATE"}}}, "must be \"kafka-cluster\""},
+		{map[string]interface{}{"acls": []interface{}{read}, "dynamic_service_account": false, "owner": owner}, "acls require dynamic_service_account"},
+		{map[string]interface{}{"acls": []interface{}{read}, "key_kind": "schema_registry", "resource": "lsrc-1"}, "acls require kafka keys"},
+		{map[string]interface{}{"acls": []interface{}{read}, "acl_api_key_id": ""}, "acls require acl_api_key_id and acl_api_key_secret"},
+		{map[string]interface{}{"acls": []interface{}{read}, "acl_api_key_secret": ""}, "acls require acl_api_key_id and acl_api_key_secret"},
+	} {
+		data := map[string]interface{}{
+			"key_kind":                "kafka",
+			"dynamic_service_account": true,
+			"resource":                "lkc-1",
+			"resource_env":            "env-1",
+			"acl_api_key_id":          fakeKafkaAdminKeyId,
+			"acl_api_key_secret":      fakeKafkaAdminKeySecret,
+		}
+		for k, v := range tc.data {
+			data[k] = v
+		}
+		resp, err := testTokenRoleCreate(t, b, s, roleName, data)
+		require.NoError(t, err)
+		require.True(t, resp.IsError(), tc.expected)
+		assert.Contains(t, resp.Error().Error(), tc.expected)
+	}
+}
+
+func TestRoleKeyKindOfLegacyRoles(t *testing.T) {
+	for resource, kind := range map[string]string{
+		"":           keyKindCloud,
+		"lkc-1":      keyKindKafka,
+		"lsrc-1":     keyKindSchemaRegistry,
+		"lksqlc-1":   keyKindKsqlDB,
+		"legacy-ids": keyKindKafka,
+	} {
+		role := &apikeyRoleEntry{Resource: resource}
+		assert.Equal(t, kind, role.keyKind(), resource)
+	}
+}
+
+func TestGetRoleMigratesLegacySharedKeyState(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	entry, err := logical.StorageEntryJSON("role/"+roleName, map[string]interface{}{
+		"owner":         owner,
+		"resource":      resource,
+		"multi_use_key": true,
+		"usage_count":   3,
+		"cc_key_id":     "LEGACYKEY",
+		"cc_key_secret": "LEGACYSECRET",
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.Put(context.Background(), entry))
+
+	role, err := b.getRole(context.Background(), s, roleName)
+	require.NoError(t, err)
+	assert.Equal(t, []sharedKeyState{{KeyId: "LEGACYKEY", Secret: "LEGACYSECRET", UntrackedLeases: 3}}, role.SharedKeys)
+
+	require.NoError(t, setRole(context.Background(), s, roleName, role))
+
+	entry, err = s.Get(context.Background(), "role/"+roleName)
+	require.NoError(t, err)
+	var stored map[string]interface{}
+	require.NoError(t, entry.DecodeJSON(&stored))
+	assert.NotContains(t, stored, "usage_count")
+	assert.NotContains(t, stored, "cc_key_id")
+	assert.NotContains(t, stored, "cc_key_secret")
+
+	resp, err := testTokenRoleRead(t, b, s)
+	require.NoError(t, err)
+	assert.Equal(t, 3, resp.Data["usage_count"])
+	assert.Equal(t, "LEGACYKEY", resp.Data["cc_key_id"])
+	requireNoSecrets(t, resp, "LEGACYSECRET")
+}
+
+func TestGetRoleMigratesSingleSharedKey(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	entry, err := logical.StorageEntryJSON("role/"+roleName, map[string]interface{}{
+		"owner":         owner,
+		"multi_use_key": true,
+		"shared_key":    map[string]interface{}{"key_id": "SHAREDKEY", "secret": "SHAREDSECRET", "usage_count": 2},
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.Put(context.Background(), entry))
+
+	role, err := b.getRole(context.Background(), s, roleName)
+	require.NoError(t, err)
+	assert.Equal(t, []sharedKeyState{{KeyId: "SHAREDKEY", Secret: "SHAREDSECRET", UntrackedLeases: 2}}, role.SharedKeys)
+	assert.Nil(t, role.LegacySharedKey)
+}
+
+func TestRoleWriteRequiresMultiUseKeyForSharedKeyMaxAge(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	resp, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"owner":              owner,
+		"owner_env":          owner_env,
+		"resource":           resource,
+		"resource_env":       resource_env,
+		"shared_key_max_age": "1h",
+	})
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+	assert.Contains(t, resp.Error().Error(), "shared_key_max_age requires multi_use_key")
+}
@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// pathStaticCredentials extends the Vault API with a `/static-creds`
+// endpoint that returns the current key of a static role.
+func pathStaticCredentials(b *ccloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-creds/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the static role",
+				Required:    true,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathStaticCredentialsRead,
+			},
+		},
+		HelpSynopsis:    pathStaticCredentialsHelpSyn,
+		HelpDescription: pathStaticCredentialsHelpDesc,
+	}
+}
+
+// pathStaticCredentialsRead returns the current key of a static role, with
+// the time left until it is rotated. No lease is created.
+func (b *ccloudBackend) pathStaticCredentialsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	role, err := getStaticRole(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == nil {
+		return logical.ErrorResponse("unknown static role: %s", name), nil
+	}
+
+	data := credentialData(role.KeyKind, role.KeyId, role.Secret)
+	data["last_rotated"] = role.LastRotated.Format(time.RFC3339)
+	data["rotation_period"] = int64(role.RotationPeriod.Seconds())
+
+	ttl := time.Until(role.NextRotation)
+	if ttl < 0 {
+		ttl = 0
+	}
+	data["ttl"] = int64(ttl.Seconds())
+
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+const pathStaticCredentialsHelpSyn = `
+Return the current API key of a static role.
+`
+
+const pathStaticCredentialsHelpDesc = `
+This path returns the API key a static role currently holds. The key is not
+leased; "ttl" is the number of seconds until it is rotated. After a rotation
+the previous key keeps working for the role's "grace_period", so applications
+should read the key again before "ttl" runs out.
+`
@@ -0,0 +1,519 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	staticRoleStoragePrefix = "static-role/"
+
+	// minStaticRotationPeriod is the shortest rotation period, since
+	// rotations are checked about once a minute
+	minStaticRotationPeriod = time.Minute
+
+	defaultStaticGracePeriod = 10 * time.Minute
+)
+
+// staticRoleEntry is a role that owns a single key of an existing service
+// account and rotates it on a schedule
+type staticRoleEntry struct {
+	Connection string `json:"connection,omitempty"`
+
+	KeyKind     string `json:"key_kind"`
+	Owner       string `json:"owner"`
+	OwnerEnv    string `json:"owner_env,omitempty"`
+	Resource    string `json:"resource,omitempty"`
+	ResourceEnv string `json:"resource_env,omitempty"`
+
+	RotationPeriod time.Duration `json:"rotation_period"`
+	GracePeriod    time.Duration `json:"grace_period"`
+
+	// The current key and its rotation status are managed by the backend
+	KeyId             string    `json:"key_id"`
+	Secret            string    `json:"secret"`
+	LastRotated       time.Time `json:"last_rotated"`
+	NextRotation      time.Time `json:"next_rotation"`
+	LastRotationError string    `json:"last_rotation_error,omitempty"`
+
+	// RetiredKeys are previous keys, deleted when their grace period ends
+	RetiredKeys []retiredKey `json:"retired_keys,omitempty"`
+}
+
+// retiredKey is a replaced key of a static role that still works until it
+// is deleted
+type retiredKey struct {
+	KeyId       string    `json:"key_id"`
+	Connection  string    `json:"connection,omitempty"`
+	DeleteAfter time.Time `json:"delete_after"`
+}
+
+func (r *staticRoleEntry) toResponseData() map[string]interface{} {
+	retiredKeyIds := make([]string, 0, len(r.RetiredKeys))
+	for _, key := range r.RetiredKeys {
+		retiredKeyIds = append(retiredKeyIds, key.KeyId)
+	}
+
+	respData := map[string]interface{}{
+		"connection":      normalizeConnection(r.Connection),
+		"key_kind":        r.KeyKind,
+		"owner":           r.Owner,
+		"owner_env":       r.OwnerEnv,
+		"resource":        r.Resource,
+		"resource_env":    r.ResourceEnv,
+		"rotation_period": int64(r.RotationPeriod.Seconds()),
+		"grace_period":    int64(r.GracePeriod.Seconds()),
+		"key_id":          r.KeyId,
+		"retired_key_ids": retiredKeyIds,
+	}
+
+	if !r.LastRotated.IsZero() {
+		respData["last_rotated"] = r.LastRotated.Format(time.RFC3339)
+	}
+	if !r.NextRotation.IsZero() {
+		respData["next_rotation"] = r.NextRotation.Format(time.RFC3339)
+	}
+	if r.LastRotationError != "" {
+		respData["last_rotation_error"] = r.LastRotationError
+	}
+
+	return respData
+}
+
+// pathStaticRoles extends the Vault API with `/static-role` endpoints for
+// roles that rotate a single key of an existing service account.
+func pathStaticRoles(b *ccloudBackend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: staticRoleStoragePrefix + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the static role",
+					Required:    true,
+				},
+				"connection": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Name of the connection used to manage the role's key. If not set, the default connection is used.",
+				},
+				"key_kind": {
+					Type:        framework.TypeLowerCaseString,
+					Description: "Kind of API key to rotate: cloud, kafka, schema_registry, ksqldb or flink.",
+					Required:    true,
+				},
+				"owner": {
+					Type:        framework.TypeString,
+					Description: "Confluent Cloud ID of the service account which owns the key.",
+					Required:    true,
+				},
+				"owner_env": {
+					Type:        framework.TypeString,
+					Description: "The owner's CCloud Environment ID, if env-scoped.",
+				},
+				"resource": {
+					Type:        framework.TypeString,
+					Description: "Confluent Cloud ID of the cluster, or the Flink region, for which the key is created. Not used for Cloud API keys.",
+				},
+				"resource_env": {
+					Type:        framework.TypeString,
+					Description: "The resource's CCloud Environment ID.",
+				},
+				"rotation_period": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How often the key is rotated. At least one minute.",
+					Required:    true,
+				},
+				"grace_period": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How long a replaced key keeps working after a rotation. Must be shorter than rotation_period. Defaults to 10 minutes.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathStaticRolesRead,
+				},
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathStaticRolesWrite,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathStaticRolesWrite,
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathStaticRolesDelete,
+				},
+			},
+			HelpSynopsis:    pathStaticRoleHelpSynopsis,
+			HelpDescription: pathStaticRoleHelpDescription,
+			ExistenceCheck:  b.pathRoleExistenceCheck,
+		},
+		{
+			Pattern: staticRoleStoragePrefix + "?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathStaticRolesList,
+				},
+			},
+			HelpSynopsis:    pathStaticRoleListHelpSynopsis,
+			HelpDescription: pathStaticRoleListHelpDescription,
+		},
+	}
+}
+
+// pathStaticRolesList lists the static roles
+func (b *ccloudBackend) pathStaticRolesList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, staticRoleStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+// pathStaticRolesRead returns a static role with its rotation status. The
+// secret of the key is only returned by static-creds.
+func (b *ccloudBackend) pathStaticRolesRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	role, err := getStaticRole(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if role == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: role.toResponseData(),
+	}, nil
+}
+
+// pathStaticRolesWrite creates or updates a static role. A new key is
+// created when the role is created or when the key's owner or resource
+// changes; the previous key is retired.
+func (b *ccloudBackend) pathStaticRolesWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	b.staticRoleLock.Lock()
+	defer b.staticRoleLock.Unlock()
+
+	role, err := getStaticRole(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	createOperation := role == nil
+	if createOperation {
+		role = &staticRoleEntry{GracePeriod: defaultStaticGracePeriod}
+	}
+	previous := *role
+
+	if connection, ok := d.GetOk("connection"); ok {
+		role.Connection = connection.(string)
+	}
+
+	for _, field := range []struct {
+		name     string
+		value    *string
+		required bool
+	}{
+		{"key_kind", &role.KeyKind, true},
+		{"owner", &role.Owner, true},
+		{"owner_env", &role.OwnerEnv, false},
+		{"resource", &role.Resource, false},
+		{"resource_env", &role.ResourceEnv, false},
+	} {
+		if value, ok := d.GetOk(field.name); ok {
+			*field.value = value.(string)
+		} else if field.required && createOperation {
+			return logical.ErrorResponse("missing %s in static role", field.name), nil
+		}
+	}
+
+	if rotationPeriod, ok := d.GetOk("rotation_period"); ok {
+		role.RotationPeriod = time.Duration(rotationPeriod.(int)) * time.Second
+	} else if createOperation {
+		return logical.ErrorResponse("missing rotation_period in static role"), nil
+	}
+
+	if gracePeriod, ok := d.GetOk("grace_period"); ok {
+		role.GracePeriod = time.Duration(gracePeriod.(int)) * time.Second
+	}
+
+	if !hasPrefix(role.Owner, serviceAccountPrefix) {
+		return logical.ErrorResponse("owner %q is not a service account (%s)", role.Owner, serviceAccountPrefix), nil
+	}
+	if err := validateKeyKind(role.KeyKind, role.Resource, role.ResourceEnv); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	if role.RotationPeriod < minStaticRotationPeriod {
+		return logical.ErrorResponse("rotation_period must be at least %s", minStaticRotationPeriod), nil
+	}
+	if role.GracePeriod < 0 || role.GracePeriod >= role.RotationPeriod {
+		return logical.ErrorResponse("grace_period must be shorter than rotation_period"), nil
+	}
+
+	keyChanged := createOperation ||
+		normalizeConnection(role.Connection) != normalizeConnection(previous.Connection) ||
+		role.KeyKind != previous.KeyKind ||
+		role.Owner != previous.Owner ||
+		role.OwnerEnv != previous.OwnerEnv ||
+		role.Resource != previous.Resource ||
+		role.ResourceEnv != previous.ResourceEnv
+
+	if keyChanged {
+		if err := b.rotateStaticRoleKey(ctx, req.Storage, name, role, previous.Connection); err != nil {
+			return nil, codedError(err)
+		}
+
+		// retired keys that cannot be deleted now are retried periodically
+		if err := b.deleteRetiredKeys(ctx, req.Storage, name, role, time.Now()); err != nil {
+			resp := &logical.Response{}
+			resp.AddWarning(fmt.Sprintf("error deleting the previous key: %s", err))
+			return resp, nil
+		}
+		return nil, nil
+	}
+
+	role.NextRotation = role.LastRotated.Add(role.RotationPeriod)
+	if err := setStaticRole(ctx, req.Storage, name, role); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// pathStaticRolesDelete deletes the keys of a static role and then the role.
+// If a key cannot be deleted, the role is kept so the delete can be retried.
+func (b *ccloudBackend) pathStaticRolesDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	b.staticRoleLock.Lock()
+	defer b.staticRoleLock.Unlock()
+
+	role, err := getStaticRole(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == nil {
+		return nil, nil
+	}
+
+	if role.KeyId != "" {
+		role.RetiredKeys = append(role.RetiredKeys, retiredKey{KeyId: role.KeyId, Connection: role.Connection})
+		role.KeyId, role.Secret = "", ""
+	}
+
+	if err := b.deleteRetiredKeys(ctx, req.Storage, name, role, time.Time{}); err != nil {
+		return nil, err
+	}
+
+	if err := req.Storage.Delete(ctx, staticRoleStoragePrefix+name); err != nil {
+		return nil, fmt.Errorf("error deleting static role: %w", err)
+	}
+
+	return nil, nil
+}
+
+// rotateStaticRoleKey creates a new key for a static role, stores it and
+// retires the previous key, which was created through previousConnection.
+// The caller must hold staticRoleLock.
+func (b *ccloudBackend) rotateStaticRoleKey(ctx context.Context, s logical.Storage, name string, role *staticRoleEntry, previousConnection string) error {
+	client, err := b.getClient(ctx, s, role.Connection)
+	if err != nil {
+		return err
+	}
+
+	displayName := "vault-static-" + name
+	description := fmt.Sprintf("Static key of role %s, rotated by the Vault Confluent Cloud secrets engine", name)
+	keyId, secret, err := client.CreateApiKey(ctx, role.Owner, role.OwnerEnv, role.Resource, role.ResourceEnv, displayName, description)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if role.KeyId != "" {
+		role.RetiredKeys = append(role.RetiredKeys, retiredKey{
+			KeyId:       role.KeyId,
+			Connection:  previousConnection,
+			DeleteAfter: now.Add(role.GracePeriod),
+		})
+	}
+
+	role.KeyId = keyId
+	role.Secret = secret
+	role.LastRotated = now
+	role.NextRotation = now.Add(role.RotationPeriod)
+	role.LastRotationError = ""
+
+	if err := setStaticRole(ctx, s, name, role); err != nil {
+		if delErr := client.DeleteApiKey(ctx, keyId); delErr != nil {
+			b.Logger().Error("failed to delete unused static key", "role", name, "key_id", keyId, "error", delErr)
+		}
+		return fmt.Errorf("error storing rotated static key: %w", err)
+	}
+
+	b.Logger().Info("rotated static key", "role", name, "key_id", keyId)
+
+	return nil
+}
+
+// deleteRetiredKeys deletes the retired keys of a static role whose grace
+// period ended before now, or all of them if now is zero, and stores the
+// keys that remain. The caller must hold staticRoleLock.
+func (b *ccloudBackend) deleteRetiredKeys(ctx context.Context, s logical.Storage, name string, role *staticRoleEntry, now time.Time) error {
+	var (
+		remaining []retiredKey
+		errs      []error
+	)
+	for _, key := range role.RetiredKeys {
+		if !now.IsZero() && now.Before(key.DeleteAfter) {
+			remaining = append(remaining, key)
+			continue
+		}
+
+		client, err := b.getClient(ctx, s, key.Connection)
+		if err == nil {
+			err = client.DeleteApiKey(ctx, key.KeyId)
+		}
+		if err != nil && !errors.Is(err, errCCloudNotFound) {
+			b.Logger().Error("failed to delete retired static key", "role", name, "key_id", key.KeyId, "error", err)
+			errs = append(errs, err)
+			remaining = append(remaining, key)
+		}
+	}
+
+	if len(remaining) == len(role.RetiredKeys) && len(errs) == 0 {
+		return nil
+	}
+
+	role.RetiredKeys = remaining
+	if err := setStaticRole(ctx, s, name, role); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// rotateStaticRolesIfDue rotates the keys of static roles whose rotation
+// period has elapsed and deletes retired keys whose grace period has ended.
+// A failed rotation is recorded on the role and retried on the next run.
+func (b *ccloudBackend) rotateStaticRolesIfDue(ctx context.Context, s logical.Storage) error {
+	names, err := s.List(ctx, staticRoleStoragePrefix)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, name := range names {
+		if err := b.rotateStaticRoleIfDue(ctx, s, name); err != nil {
+			errs = append(errs, fmt.Errorf("static role %q: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (b *ccloudBackend) rotateStaticRoleIfDue(ctx context.Context, s logical.Storage, name string) error {
+	b.staticRoleLock.Lock()
+	defer b.staticRoleLock.Unlock()
+
+	role, err := getStaticRole(ctx, s, name)
+	if err != nil || role == nil {
+		return err
+	}
+
+	if !time.Now().Before(role.NextRotation) {
+		if err := b.rotateStaticRole(ctx, s, name, role); err != nil {
+			return err
+		}
+	}
+
+	return b.deleteRetiredKeys(ctx, s, name, role, time.Now())
+}
+
+// rotateStaticRole rotates the key of a static role and records a failure
+// on the role. The caller must hold staticRoleLock.
+func (b *ccloudBackend) rotateStaticRole(ctx context.Context, s logical.Storage, name string, role *staticRoleEntry) error {
+	if err := b.rotateStaticRoleKey(ctx, s, name, role, role.Connection); err != nil {
+		b.Logger().Error("failed to rotate static key", "role", name, "error", err)
+
+		// the rotation is retried on the next run, with the role as stored
+		role, getErr := getStaticRole(ctx, s, name)
+		if getErr == nil && role != nil {
+			role.LastRotationError = err.Error()
+			getErr = setStaticRole(ctx, s, name, role)
+		}
+		if getErr != nil {
+			b.Logger().Error("failed to save static role rotation status", "role", name, "error", getErr)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// setStaticRole stores a static role
+func setStaticRole(ctx context.Context, s logical.Storage, name string, role *staticRoleEntry) error {
+	entry, err := logical.StorageEntryJSON(staticRoleStoragePrefix+name, role)
+	if err != nil {
+		return err
+	}
+
+	if entry == nil {
+		return fmt.Errorf("failed to create storage entry for static role")
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// getStaticRole reads a static role. It returns nil if the role does not
+// exist.
+func getStaticRole(ctx context.Context, s logical.Storage, name string) (*staticRoleEntry, error) {
+	if name == "" {
+		return nil, fmt.Errorf("missing static role name")
+	}
+
+	entry, err := s.Get(ctx, staticRoleStoragePrefix+name)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var role staticRoleEntry
+	if err := entry.DecodeJSON(&role); err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+const (
+	pathStaticRoleHelpSynopsis    = `Manages static roles, which rotate the key of an existing service account.`
+	pathStaticRoleHelpDescription = `
+A static role owns exactly one API key of an existing service account, for
+applications that need a stable key rather than leases. The key is created
+when the role is written and returned by "static-creds/<name>".
+
+Every "rotation_period", a new key is created and stored, and the previous
+key is deleted once "grace_period" has passed, so applications have time to
+pick up the new key. Changing the owner, resource or connection of a role
+rotates its key right away.
+
+Reading the role reports the current key ID, "last_rotated",
+"next_rotation", the keys waiting to be deleted, and the error of the last
+rotation if it failed. Failed rotations are retried about once a minute.
+Deleting the role deletes its keys.
+`
+
+	pathStaticRoleListHelpSynopsis    = `List the static roles.`
+	pathStaticRoleListHelpDescription = `Static roles will be listed by name.`
+)
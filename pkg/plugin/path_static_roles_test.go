@@ -0,0 +1,234 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const staticRoleName = "legacy-app"
+
+func testStaticRoleWrite(t *testing.T, b *ccloudBackend, s logical.Storage, data map[string]interface{}) (*logical.Response, error) {
+	t.Helper()
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      staticRoleStoragePrefix + staticRoleName,
+		Data:      data,
+		Storage:   s,
+	})
+}
+
+func testStaticCredsRead(t *testing.T, b *ccloudBackend, s logical.Storage) map[string]interface{} {
+	t.Helper()
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "static-creds/" + staticRoleName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+	require.Nil(t, resp.Secret)
+	return resp.Data
+}
+
+// expireStaticRotation makes the static role due for rotation and its
+// retired keys due for deletion
+func expireStaticRotation(t *testing.T, s logical.Storage) {
+	t.Helper()
+	role, err := getStaticRole(context.Background(), s, staticRoleName)
+	require.NoError(t, err)
+	role.NextRotation = time.Now().Add(-time.Minute)
+	for i := range role.RetiredKeys {
+		role.RetiredKeys[i].DeleteAfter = time.Now().Add(-time.Minute)
+	}
+	require.NoError(t, setStaticRole(context.Background(), s, staticRoleName, role))
+}
+
+func TestStaticRoleCreatesAndRotatesKey(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	resp, err := testStaticRoleWrite(t, b, s, map[string]interface{}{
+		"key_kind":        "kafka",
+		"owner":           fakeRootKeyOwner,
+		"resource":        "lkc-1",
+		"resource_env":    "env-1",
+		"rotation_period": "24h",
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	creds := testStaticCredsRead(t, b, s)
+	firstKeyId := creds["key_id"].(string)
+	assert.Equal(t, fakeRootKeyOwner, fake.key(firstKeyId).Owner)
+	assert.Equal(t, "vault-static-"+staticRoleName, fake.key(firstKeyId).DisplayName)
+	assert.Contains(t, creds["sasl.jaas.config"], firstKeyId)
+	assert.InDelta(t, 24*60*60, creds["ttl"], 60)
+
+	// not due yet
+	require.NoError(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+	assert.Equal(t, firstKeyId, testStaticCredsRead(t, b, s)["key_id"])
+
+	expireStaticRotation(t, s)
+	require.NoError(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+
+	secondKeyId := testStaticCredsRead(t, b, s)["key_id"].(string)
+	assert.NotEqual(t, firstKeyId, secondKeyId)
+
+	// the previous key works during the grace period
+	assert.True(t, fake.hasKey(firstKeyId))
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      staticRoleStoragePrefix + staticRoleName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, secondKeyId, resp.Data["key_id"])
+	assert.Equal(t, []string{firstKeyId}, resp.Data["retired_key_ids"])
+	assert.NotEmpty(t, resp.Data["last_rotated"])
+	assert.NotContains(t, resp.Data, "secret")
+	assert.NotContains(t, resp.Data, "last_rotation_error")
+
+	nextRotation, err := time.Parse(time.RFC3339, resp.Data["next_rotation"].(string))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), nextRotation, time.Minute)
+
+	role, err := getStaticRole(context.Background(), s, staticRoleName)
+	require.NoError(t, err)
+	role.RetiredKeys[0].DeleteAfter = time.Now().Add(-time.Minute)
+	require.NoError(t, setStaticRole(context.Background(), s, staticRoleName, role))
+
+	require.NoError(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+	assert.False(t, fake.hasKey(firstKeyId))
+	assert.True(t, fake.hasKey(secondKeyId))
+}
+
+func TestStaticRoleRecordsFailedRotation(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	_, err := testStaticRoleWrite(t, b, s, map[string]interface{}{
+		"key_kind":        "cloud",
+		"owner":           fakeRootKeyOwner,
+		"rotation_period": "1h",
+	})
+	require.NoError(t, err)
+	keyId := testStaticCredsRead(t, b, s)["key_id"]
+
+	expireStaticRotation(t, s)
+	fake.failNext(http.MethodPost, fakeFailure{status: http.StatusBadRequest})
+	require.Error(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+
+	role, err := getStaticRole(context.Background(), s, staticRoleName)
+	require.NoError(t, err)
+	assert.Equal(t, keyId, role.KeyId)
+	assert.NotEmpty(t, role.LastRotationError)
+
+	// retried on the next run
+	require.NoError(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+	role, err = getStaticRole(context.Background(), s, staticRoleName)
+	require.NoError(t, err)
+	assert.NotEqual(t, keyId, role.KeyId)
+	assert.Empty(t, role.LastRotationError)
+}
+
+func TestStaticRoleUpdateRotatesChangedKey(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	_, err := testStaticRoleWrite(t, b, s, map[string]interface{}{
+		"key_kind":        "cloud",
+		"owner":           fakeRootKeyOwner,
+		"rotation_period": "1h",
+		"grace_period":    0,
+	})
+	require.NoError(t, err)
+	keyId := testStaticCredsRead(t, b, s)["key_id"].(string)
+
+	// a new rotation period keeps the key
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      staticRoleStoragePrefix + staticRoleName,
+		Data:      map[string]interface{}{"rotation_period": "2h"},
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	creds := testStaticCredsRead(t, b, s)
+	assert.Equal(t, keyId, creds["key_id"])
+	assert.InDelta(t, 2*60*60, creds["ttl"], 60)
+
+	// a new owner does not, and the old key is deleted without a grace period
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      staticRoleStoragePrefix + staticRoleName,
+		Data:      map[string]interface{}{"owner": "sa-other"},
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	newKeyId := testStaticCredsRead(t, b, s)["key_id"].(string)
+	assert.NotEqual(t, keyId, newKeyId)
+	assert.Equal(t, "sa-other", fake.key(newKeyId).Owner)
+	assert.False(t, fake.hasKey(keyId))
+}
+
+func TestStaticRoleDeleteDeletesKeys(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := getTestBackend(t)
+	fake.configure(t, b, s)
+
+	_, err := testStaticRoleWrite(t, b, s, map[string]interface{}{
+		"key_kind":        "cloud",
+		"owner":           fakeRootKeyOwner,
+		"rotation_period": "1h",
+	})
+	require.NoError(t, err)
+
+	expireStaticRotation(t, s)
+	role, err := getStaticRole(context.Background(), s, staticRoleName)
+	require.NoError(t, err)
+	require.NoError(t, b.rotateStaticRole(context.Background(), s, staticRoleName, role))
+	require.Equal(t, 3, fake.keyCount())
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      staticRoleStoragePrefix + staticRoleName,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, fake.keyCount())
+
+	role, err = getStaticRole(context.Background(), s, staticRoleName)
+	require.NoError(t, err)
+	assert.Nil(t, role)
+}
+
+func TestStaticRoleWriteValidatesFields(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	for _, tc := range []struct {
+		data     map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"owner": fakeRootKeyOwner, "rotation_period": "1h"}, "missing key_kind"},
+		{map[string]interface{}{"key_kind": "cloud", "rotation_period": "1h"}, "missing owner"},
+		{map[string]interface{}{"key_kind": "cloud", "owner": fakeRootKeyOwner}, "missing rotation_period"},
+		{map[string]interface{}{"key_kind": "cloud", "owner": "u-1", "rotation_period": "1h"}, "is not a service account"},
+		{map[string]interface{}{"key_kind": "kafka", "owner": fakeRootKeyOwner, "rotation_period": "1h"}, "resource is required"},
+		{map[string]interface{}{"key_kind": "cloud", "owner": fakeRootKeyOwner, "rotation_period": "30s"}, "at least 1m0s"},
+		{map[string]interface{}{"key_kind": "cloud", "owner": fakeRootKeyOwner, "rotation_period": "1h", "grace_period": "1h"}, "grace_period must be shorter"},
+	} {
+		resp, err := testStaticRoleWrite(t, b, s, tc.data)
+		require.NoError(t, err)
+		require.True(t, resp.IsError(), tc.expected)
+		assert.Contains(t, resp.Error().Error(), tc.expected)
+	}
+}
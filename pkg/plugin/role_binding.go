@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// crnPrefix is the prefix of Confluent Resource Names
+const crnPrefix = "crn://"
+
+// roleBinding is an RBAC role a role binds to the owner of each key, on the
+// resources matching a CRN pattern
+type roleBinding struct {
+	RoleName   string `json:"role_name"`
+	CRNPattern string `json:"crn_pattern"`
+}
+
+// parseRoleBindings parses the role_bindings field of a role. Bindings are
+// either objects with a role_name and crn_pattern, or strings of the form
+// "<role_name>=<crn_pattern>".
+func parseRoleBindings(raw []interface{}) ([]roleBinding, error) {
+	bindings := make([]roleBinding, 0, len(raw))
+	for i, item := range raw {
+		var binding roleBinding
+		switch item := item.(type) {
+		case string:
+			binding.RoleName, binding.CRNPattern, _ = strings.Cut(item, "=")
+		case map[string]interface{}:
+			binding.RoleName, _ = item["role_name"].(string)
+			binding.CRNPattern, _ = item["crn_pattern"].(string)
+		default:
+			return nil, fmt.Errorf("role binding %d must be an object or a string", i)
+		}
+
+		if err := binding.validate(); err != nil {
+			return nil, fmt.Errorf("role binding %d: %w", i, err)
+		}
+		bindings = append(bindings, binding)
+	}
+
+	return bindings, nil
+}
+
+func (rb roleBinding) validate() error {
+	if rb.RoleName == "" {
+		return errors.New("role_name is required")
+	}
+	if !strings.HasPrefix(rb.CRNPattern, crnPrefix) {
+		return fmt.Errorf("crn_pattern must be a CRN (%s), got %q", crnPrefix, rb.CRNPattern)
+	}
+	return nil
+}
+
+// roleBindingIds reads the IDs of the role bindings of a lease from its
+// internal data, where they are decoded from JSON
+func roleBindingIds(internalData map[string]interface{}) ([]string, error) {
+	var ids []string
+	switch raw := internalData["role_binding_ids"].(type) {
+	case nil:
+	case []string:
+		ids = raw
+	case []interface{}:
+		for _, id := range raw {
+			s, ok := id.(string)
+			if !ok {
+				return nil, errors.New("invalid value for role_binding_ids in secret internal data")
+			}
+			ids = append(ids, s)
+		}
+	default:
+		return nil, errors.New("invalid value for role_binding_ids in secret internal data")
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,114 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// Ways a role can be validated against Confluent Cloud when it is written
+const (
+	roleValidationNone  = "none"
+	roleValidationWarn  = "warn"
+	roleValidationError = "error"
+)
+
+// ID prefixes of the Confluent Cloud objects a role refers to
+const (
+	serviceAccountPrefix        = "sa-"
+	userPrefix                  = "u-"
+	environmentPrefix           = "env-"
+	kafkaClusterPrefix          = "lkc-"
+	schemaRegistryClusterPrefix = "lsrc-"
+)
+
+// validateRole checks that the owner and resource of a role have the right
+// ID prefixes and exist in Confluent Cloud. It returns the problems found.
+func (b *ccloudBackend) validateRole(ctx context.Context, s logical.Storage, role *apikeyRoleEntry) []string {
+	var problems []string
+
+	// dynamic service accounts are created with each lease
+	checkOwner := !role.DynamicServiceAccount
+
+	ownerValid := checkOwner && hasPrefix(role.Owner, serviceAccountPrefix, userPrefix)
+	if checkOwner && !ownerValid {
+		problems = append(problems, fmt.Sprintf("owner %q is neither a service account (%s) nor a user (%s)", role.Owner, serviceAccountPrefix, userPrefix))
+	}
+
+	kind := role.keyKind()
+	spec := keyKinds[kind]
+	resourceValid := role.Resource == "" || strings.HasPrefix(role.Resource, spec.resourcePrefix)
+	if !resourceValid {
+		problems = append(problems, fmt.Sprintf("resource %q is not a %s (%s)", role.Resource, spec.resourceName, spec.resourcePrefix))
+	}
+
+	for _, env := range []struct{ field, id string }{
+		{"owner_env", role.OwnerEnv},
+		{"resource_env", role.ResourceEnv},
+	} {
+		if env.id != "" && !strings.HasPrefix(env.id, environmentPrefix) {
+			problems = append(problems, fmt.Sprintf("%s %q is not an environment (%s)", env.field, env.id, environmentPrefix))
+		}
+	}
+
+	if role.Resource != "" && role.ResourceEnv == "" {
+		resourceValid = false
+		problems = append(problems, fmt.Sprintf("resource_env is required to look up resource %q", role.Resource))
+	}
+
+	if !ownerValid && (!resourceValid || role.Resource == "") {
+		return problems
+	}
+
+	client, err := b.getClient(ctx, s, role.Connection)
+	if err != nil {
+		return append(problems, fmt.Sprintf("unable to validate the role against Confluent Cloud: %s", err))
+	}
+
+	if ownerValid {
+		var exists bool
+		if strings.HasPrefix(role.Owner, serviceAccountPrefix) {
+			exists, err = client.ServiceAccountExists(ctx, role.Owner)
+		} else {
+			exists, err = client.UserExists(ctx, role.Owner)
+		}
+
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("unable to look up owner %q: %s", role.Owner, err))
+		case !exists:
+			problems = append(problems, fmt.Sprintf("owner %q does not exist", role.Owner))
+		}
+	}
+
+	// Flink regions are not looked up
+	lookupResource := map[string]func(ctx context.Context, id, environment string) (bool, error){
+		keyKindKafka:          client.KafkaClusterExists,
+		keyKindSchemaRegistry: client.SchemaRegistryClusterExists,
+		keyKindKsqlDB:         client.KsqlDBClusterExists,
+	}[kind]
+
+	if resourceValid && role.Resource != "" && lookupResource != nil {
+		exists, err := lookupResource(ctx, role.Resource, role.ResourceEnv)
+
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("unable to look up resource %q: %s", role.Resource, err))
+		case !exists:
+			problems = append(problems, fmt.Sprintf("resource %q does not exist in environment %q", role.Resource, role.ResourceEnv))
+		}
+	}
+
+	return problems
+}
+
+func hasPrefix(s string, prefixes ...string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	storageVersionPath = "storage-version"
+
+	// configStorageVersion and roleStorageVersion are the versions of the
+	// configuration and role entries written by this version of the plugin
+	configStorageVersion = 1
+	roleStorageVersion   = 1
+)
+
+// storageVersionEntry records the version of the mount's storage. While an
+// upgrade runs, Target is the version it upgrades to, so an upgrade that was
+// interrupted is known to be resumed.
+type storageVersionEntry struct {
+	Version   int       `json:"version"`
+	Target    int       `json:"target,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// storageMigration upgrades the stored entries by one storage version. It
+// skips entries that were already upgraded, so it can run again after an
+// interruption.
+type storageMigration func(ctx context.Context, b *ccloudBackend, s logical.Storage) error
+
+// storageMigrations upgrade the storage in order: the migration at index i
+// upgrades it from version i to version i+1
+var storageMigrations = []storageMigration{
+	migrateUnversionedEntries,
+}
+
+// currentStorageVersion returns the storage version of this version of the
+// plugin
+func currentStorageVersion() int {
+	return len(storageMigrations)
+}
+
+// initialize upgrades the mount's storage and starts the pool worker when
+// Vault starts the backend on a node that writes storage. The worker starts
+// even if the upgrade fails, since roles of every earlier version are read,
+// and pools of roles it cannot read are left alone.
+func (b *ccloudBackend) initialize(ctx context.Context, req *logical.InitializationRequest) error {
+	if !b.writesStorage() {
+		return nil
+	}
+
+	err := b.migrateStorage(ctx, req.Storage)
+	if err != nil {
+		b.Logger().Error("failed to upgrade storage", "error", err)
+	}
+
+	b.startPoolWorker(req.Storage)
+	return err
+}
+
+// migrateStorage runs the migrations from the recorded storage version to
+// the current one. The version is recorded after every migration, so an
+// interrupted upgrade resumes with the migration that did not complete.
+func (b *ccloudBackend) migrateStorage(ctx context.Context, s logical.Storage) error {
+	state, err := getStorageVersion(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	if state.Version > currentStorageVersion() {
+		return fmt.Errorf("storage was upgraded to version %d by a newer version of the plugin, which supports up to version %d", state.Version, currentStorageVersion())
+	}
+
+	if state.Target > 0 {
+		b.Logger().Warn("resuming interrupted storage upgrade", "from", state.Version, "to", state.Target)
+	}
+
+	for state.Version < currentStorageVersion() {
+		state.Target = state.Version + 1
+		if err := setStorageVersion(ctx, s, state); err != nil {
+			return err
+		}
+
+		b.Logger().Info("upgrading storage", "from", state.Version, "to", state.Target)
+		if err := storageMigrations[state.Version](ctx, b, s); err != nil {
+			return fmt.Errorf("error upgrading storage to version %d: %w", state.Target, err)
+		}
+
+		state.Version, state.Target = state.Target, 0
+		if err := setStorageVersion(ctx, s, state); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateUnversionedEntries upgrades the configuration and role entries
+// written before entries were versioned. The configuration only gains its
+// version; roles also get the current shape of their shared key state.
+func migrateUnversionedEntries(ctx context.Context, b *ccloudBackend, s logical.Storage) error {
+	connections, err := listConnections(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	for _, connection := range connections {
+		if err := setEntryVersion(ctx, s, connectionStoragePath(connection), configStorageVersion); err != nil {
+			return fmt.Errorf("error upgrading configuration of connection %q: %w", connection, err)
+		}
+	}
+
+	roles, err := s.List(ctx, "role/")
+	if err != nil {
+		return err
+	}
+
+	for _, name := range roles {
+		if err := b.migrateRole(ctx, s, name); err != nil {
+			return fmt.Errorf("error upgrading role %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// migrateRole rewrites a role written with an earlier storage version
+func (b *ccloudBackend) migrateRole(ctx context.Context, s logical.Storage, name string) error {
+	lock := locksutil.LockForKey(b.roleLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := b.getRole(ctx, s, name)
+	if err != nil || role == nil || role.Version >= roleStorageVersion {
+		return err
+	}
+
+	return setRole(ctx, s, name, role)
+}
+
+// setEntryVersion sets the version of a stored JSON entry written with an
+// earlier version, leaving its other fields as they are
+func setEntryVersion(ctx context.Context, s logical.Storage, path string, version int) error {
+	entry, err := s.Get(ctx, path)
+	if err != nil || entry == nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := entry.DecodeJSON(&fields); err != nil {
+		return err
+	}
+
+	var stored int
+	if raw, ok := fields["version"]; ok {
+		if err := json.Unmarshal(raw, &stored); err != nil {
+			return fmt.Errorf("invalid version: %w", err)
+		}
+	}
+	if stored >= version {
+		return nil
+	}
+
+	fields["version"] = json.RawMessage(strconv.Itoa(version))
+	entry, err = logical.StorageEntryJSON(path, fields)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// getStorageVersion reads the version of the mount's storage. Storage
+// written before it was versioned has version 0.
+func getStorageVersion(ctx context.Context, s logical.Storage) (*storageVersionEntry, error) {
+	entry, err := s.Get(ctx, storageVersionPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var state storageVersionEntry
+	if entry != nil {
+		if err := entry.DecodeJSON(&state); err != nil {
+			return nil, fmt.Errorf("error reading storage version: %w", err)
+		}
+	}
+
+	return &state, nil
+}
+
+// setStorageVersion records the version of the mount's storage
+func setStorageVersion(ctx context.Context, s logical.Storage, state *storageVersionEntry) error {
+	state.UpdatedAt = time.Now()
+	entry, err := logical.StorageEntryJSON(storageVersionPath, state)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
@@ -0,0 +1,151 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// putRawEntry stores an entry as an earlier version of the plugin wrote it
+func putRawEntry(t *testing.T, s logical.Storage, path string, fields map[string]interface{}) {
+	t.Helper()
+	entry, err := logical.StorageEntryJSON(path, fields)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(context.Background(), entry))
+}
+
+// getRawEntry reads an entry without decoding it into its type. Numbers are
+// decoded as json.Number.
+func getRawEntry(t *testing.T, s logical.Storage, path string) map[string]interface{} {
+	t.Helper()
+	entry, err := s.Get(context.Background(), path)
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+
+	var fields map[string]interface{}
+	require.NoError(t, entry.DecodeJSON(&fields))
+	return fields
+}
+
+func initialize(t *testing.T, b *ccloudBackend, s logical.Storage) error {
+	t.Helper()
+	return b.Initialize(context.Background(), &logical.InitializationRequest{Storage: s})
+}
+
+func TestInitializeUpgradesStoredEntries(t *testing.T) {
+	b, s := newTestBackend(t, logical.TestSystemView())
+
+	putRawEntry(t, s, configStoragePath, map[string]interface{}{
+		"api_key_id":     "KEY",
+		"api_key_secret": "SECRET",
+		"url":            "https://api.confluent.cloud",
+	})
+	putRawEntry(t, s, connectionStoragePath("other"), map[string]interface{}{
+		"api_key_id": "OTHERKEY",
+	})
+	putRawEntry(t, s, "role/"+roleName, map[string]interface{}{
+		"owner":         owner,
+		"multi_use_key": true,
+		"usage_count":   2,
+		"cc_key_id":     "LEGACYKEY",
+		"cc_key_secret": "LEGACYSECRET",
+	})
+
+	require.NoError(t, initialize(t, b, s))
+
+	// the configuration only gains its version, so defaults are not stored
+	assert.Equal(t, map[string]interface{}{
+		"version":        json.Number(strconv.Itoa(configStorageVersion)),
+		"api_key_id":     "KEY",
+		"api_key_secret": "SECRET",
+		"url":            "https://api.confluent.cloud",
+	}, getRawEntry(t, s, configStoragePath))
+	assert.Equal(t, json.Number(strconv.Itoa(configStorageVersion)), getRawEntry(t, s, connectionStoragePath("other"))["version"])
+
+	role := getRawEntry(t, s, "role/"+roleName)
+	assert.Equal(t, json.Number(strconv.Itoa(roleStorageVersion)), role["version"])
+	assert.NotContains(t, role, "cc_key_id")
+	require.Len(t, role["shared_keys"], 1)
+	assert.Equal(t, "LEGACYKEY", role["shared_keys"].([]interface{})[0].(map[string]interface{})["key_id"])
+
+	state, err := getStorageVersion(context.Background(), s)
+	require.NoError(t, err)
+	assert.Equal(t, currentStorageVersion(), state.Version)
+	assert.Zero(t, state.Target)
+
+	// an upgraded mount is left as it is
+	require.NoError(t, initialize(t, b, s))
+	updated, err := getStorageVersion(context.Background(), s)
+	require.NoError(t, err)
+	assert.Equal(t, state.UpdatedAt, updated.UpdatedAt)
+}
+
+func TestInitializeResumesInterruptedUpgrade(t *testing.T) {
+	b, s := newTestBackend(t, logical.TestSystemView())
+
+	// the upgrade stopped after rewriting the first role
+	require.NoError(t, setStorageVersion(context.Background(), s, &storageVersionEntry{Version: 0, Target: 1}))
+	require.NoError(t, setRole(context.Background(), s, "first", &apikeyRoleEntry{
+		Owner:       owner,
+		MultiUseKey: true,
+		SharedKeys:  []sharedKeyState{{KeyId: "KEY1", Leases: []string{"lease-1"}}},
+	}))
+	putRawEntry(t, s, "role/second", map[string]interface{}{
+		"owner":         owner,
+		"multi_use_key": true,
+		"shared_key":    map[string]interface{}{"key_id": "KEY2", "usage_count": 1},
+	})
+
+	require.NoError(t, initialize(t, b, s))
+
+	first, err := b.getRole(context.Background(), s, "first")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"lease-1"}, first.SharedKeys[0].Leases)
+
+	second := getRawEntry(t, s, "role/second")
+	assert.Equal(t, json.Number(strconv.Itoa(roleStorageVersion)), second["version"])
+	assert.NotContains(t, second, "shared_key")
+
+	state, err := getStorageVersion(context.Background(), s)
+	require.NoError(t, err)
+	assert.Equal(t, &storageVersionEntry{Version: currentStorageVersion(), UpdatedAt: state.UpdatedAt}, state)
+}
+
+func TestNewerStorageVersionsAreRejected(t *testing.T) {
+	b, s := newTestBackend(t, logical.TestSystemView())
+
+	putRawEntry(t, s, "role/"+roleName, map[string]interface{}{"version": roleStorageVersion + 1, "owner": owner})
+	_, err := b.getRole(context.Background(), s, roleName)
+	require.ErrorContains(t, err, "newer version of the plugin")
+
+	putRawEntry(t, s, configStoragePath, map[string]interface{}{"version": configStorageVersion + 1})
+	_, err = getConfig(context.Background(), s, defaultConnectionName)
+	require.ErrorContains(t, err, "newer version of the plugin")
+
+	require.NoError(t, setStorageVersion(context.Background(), s, &storageVersionEntry{Version: currentStorageVersion() + 1}))
+	require.ErrorContains(t, initialize(t, b, s), "newer version of the plugin")
+}
+
+func TestInitializeStartsPoolWorkerWhenUpgradeFails(t *testing.T) {
+	fake := newFakeCCloud(t)
+	b, s := newTestBackend(t, logical.TestSystemView())
+
+	require.NoError(t, setStorageVersion(context.Background(), s, &storageVersionEntry{Version: currentStorageVersion() + 1}))
+	require.Error(t, initialize(t, b, s))
+
+	fake.configure(t, b, s)
+	_, err := testTokenRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"key_kind":  "cloud",
+		"owner":     fakeRootKeyOwner,
+		"pool_size": 1,
+	})
+	require.NoError(t, err)
+
+	// the pool is still refilled in the background
+	waitForPool(t, b, s, 1)
+}